@@ -47,49 +47,46 @@ func main() {
 }
 
 func blogRoutes(r chi.Router) {
-	r.Get("/blog", func(w http.ResponseWriter, r *http.Request) {
+	r.Method("GET", "/blog", golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		blogs, err := db.GetAllBlogs()
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		golte.RenderPage(w, r, "page/blogs", map[string]any{
 			"blogs": blogs,
 		})
-	})
+		return nil
+	}))
 
-	r.Get("/blog/{id:[0-9+]}", func(w http.ResponseWriter, r *http.Request) {
+	r.Method("GET", "/blog/{id:[0-9+]}", golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return &golte.HTTPError{Status: http.StatusBadRequest, Message: err.Error()}
 		}
 
 		blog, err := db.GetBlog(id)
 		if errors.Is(err, database.ErrBlogNotExist) {
-			golte.RenderError(w, r, err.Error(), http.StatusNotFound)
-			return
+			return &golte.HTTPError{Status: http.StatusNotFound, Message: err.Error()}
 		} else if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		golte.RenderPage(w, r, "page/blog", map[string]any{
 			"blog": blog,
 		})
-	})
+		return nil
+	}))
 
-	r.Post("/blog", func(w http.ResponseWriter, r *http.Request) {
+	r.Method("POST", "/blog", golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		if username(r) == "" {
 			w.WriteHeader(http.StatusUnauthorized)
-			return
+			return nil
 		}
 
 		err := r.ParseForm()
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusBadRequest)
-			return
+			return &golte.HTTPError{Status: http.StatusBadRequest, Message: err.Error()}
 		}
 
 		title := r.Form.Get("title")
@@ -97,47 +94,45 @@ func blogRoutes(r chi.Router) {
 
 		err = db.PostBlog(username(r), title, body)
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		http.Redirect(w, r, "/blog", http.StatusSeeOther)
-	})
+		return nil
+	}))
 
-	r.Get("/new", func(w http.ResponseWriter, r *http.Request) {
+	r.Method("GET", "/new", golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		if username(r) == "" {
-			golte.RenderError(w, r, "Unauthorized", http.StatusUnauthorized)
-			return
+			return &golte.HTTPError{Status: http.StatusUnauthorized}
 		}
 
 		golte.RenderPage(w, r, "page/new", nil)
-	})
+		return nil
+	}))
 
-	r.Get("/user/{username}", func(w http.ResponseWriter, r *http.Request) {
+	r.Method("GET", "/user/{username}", golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		blogs, err := db.GetUserBlogs(chi.URLParam(r, "username"))
 
 		if errors.Is(err, database.ErrUserNotExist) {
-			golte.RenderError(w, r, err.Error(), http.StatusNotFound)
-			return
+			return &golte.HTTPError{Status: http.StatusNotFound, Message: err.Error()}
 		} else if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		golte.RenderPage(w, r, "page/userblogs", map[string]any{
 			"blogs": blogs,
 		})
-	})
+		return nil
+	}))
 }
 
 func authRoutes(r chi.Router) {
 	r.Get("/login", golte.Page("page/login"))
 
-	r.Post("/login", func(w http.ResponseWriter, r *http.Request) {
+	r.Method("POST", "/login", golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		err := r.ParseForm()
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusBadRequest)
-			return
+			return &golte.HTTPError{Status: http.StatusBadRequest, Message: err.Error()}
 		}
 
 		username := r.Form.Get("username")
@@ -145,19 +140,17 @@ func authRoutes(r chi.Router) {
 
 		exists, err := db.AccountExists(username, password)
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		if !exists {
 			http.Redirect(w, r, "/login?err=2", http.StatusSeeOther)
-			return
+			return nil
 		}
 
 		id, err := db.CreateSession(username)
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		http.SetCookie(w, &http.Cookie{
@@ -167,7 +160,8 @@ func authRoutes(r chi.Router) {
 		})
 
 		http.Redirect(w, r, "/blog", http.StatusSeeOther)
-	})
+		return nil
+	}))
 
 	r.Get("/logout", func(w http.ResponseWriter, r *http.Request) {
 		http.SetCookie(w, &http.Cookie{
@@ -177,11 +171,10 @@ func authRoutes(r chi.Router) {
 		http.Redirect(w, r, "/blog", http.StatusFound)
 	})
 
-	r.Post("/register", func(w http.ResponseWriter, r *http.Request) {
+	r.Method("POST", "/register", golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		err := r.ParseForm()
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusBadRequest)
-			return
+			return &golte.HTTPError{Status: http.StatusBadRequest, Message: err.Error()}
 		}
 
 		username := r.Form.Get("username")
@@ -190,16 +183,14 @@ func authRoutes(r chi.Router) {
 		err = db.RegisterAccount(username, password)
 		if errors.Is(err, database.ErrAccountAlreadyExists) {
 			http.Redirect(w, r, "/login?err=1", http.StatusSeeOther)
-			return
+			return nil
 		} else if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		id, err := db.CreateSession(username)
 		if err != nil {
-			golte.RenderError(w, r, err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		http.SetCookie(w, &http.Cookie{
@@ -209,7 +200,8 @@ func authRoutes(r chi.Router) {
 		})
 
 		http.Redirect(w, r, "/blog", http.StatusSeeOther)
-	})
+		return nil
+	}))
 }
 
 // auth is a middleware that adds username to the request context based on the session cookie.
@@ -0,0 +1,92 @@
+package golte
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/nichady/golte/render"
+)
+
+// assetHandler serves the client files of the build.
+// Assets are served under the base path, as well as on the root in case the base path was stripped by a proxy.
+type assetHandler struct {
+	files    *fileServer
+	prefixes []string
+}
+
+func newAssetHandler(clientDir fs.FS, assets, basePath string, cors bool) *assetHandler {
+	files := newFileServer(clientDir)
+	files.cors = cors
+
+	prefixes := []string{basePath + "/" + assets + "/"}
+	if basePath != "" {
+		prefixes = append(prefixes, "/"+assets+"/")
+	}
+
+	return &assetHandler{files: files, prefixes: prefixes}
+}
+
+// serve serves the request if it is for an asset, reporting whether it was served.
+func (h *assetHandler) serve(w http.ResponseWriter, r *http.Request) bool {
+	for _, prefix := range h.prefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			http.StripPrefix(prefix, h.files).ServeHTTP(w, r)
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP serves the request if it is for an asset, and responds with 404 otherwise.
+func (h *assetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.serve(w, r) {
+		http.NotFound(w, r)
+	}
+}
+
+// AssetHandler returns a handler that serves the assets of the build, for use with [WithoutAssetServing].
+// It serves requests under [AssetPrefix] and responds with 404 to any other request,
+// so it can be mounted on the prefix of any router and wrapped with middleware, for example:
+//
+//	r.With(auth).Handle(golte.AssetPrefix(build.FS)+"*", golte.AssetHandler(build.FS))
+//
+// The same options should be passed as to [New]; only the ones that affect assets are used.
+func AssetHandler(fsys fs.FS, opts ...Option) http.Handler {
+	o, info := assetInfo(fsys, opts)
+
+	clientDir, err := fs.Sub(fsys, "client")
+	if err != nil {
+		panic(err)
+	}
+
+	return newAssetHandler(clientDir, info.Assets, o.basePath, o.assetOrigin != "")
+}
+
+// AssetPrefix returns the path that the assets of the build are served under,
+// such as "/golte_/", or "/billing/golte_/" with [WithBasePath].
+// The same options should be passed as to [New].
+func AssetPrefix(fsys fs.FS, opts ...Option) string {
+	o, info := assetInfo(fsys, opts)
+	return o.basePath + "/" + info.Assets + "/"
+}
+
+// assetInfo applies the options and reads the info of the build, without creating a renderer.
+func assetInfo(fsys fs.FS, opts []Option) (options, render.Info) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	serverDir, err := fs.Sub(fsys, "server")
+	if err != nil {
+		panic(err)
+	}
+
+	info, err := render.ReadInfo(serverDir)
+	if err != nil {
+		panic(err)
+	}
+
+	return o, info
+}
@@ -0,0 +1,39 @@
+package golte
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAssetHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"server/info.js":      {Data: []byte(`exports.Assets = "golte_";`)},
+		"client/entries/a.js": {Data: []byte("a")},
+	}
+
+	if prefix := AssetPrefix(fsys); prefix != "/golte_/" {
+		t.Errorf("unexpected prefix %q", prefix)
+	}
+	if prefix := AssetPrefix(fsys, WithBasePath("billing/")); prefix != "/billing/golte_/" {
+		t.Errorf("unexpected prefix %q", prefix)
+	}
+
+	h := AssetHandler(fsys, WithBasePath("/billing"), WithAssetOrigin("https://cdn.example.com"))
+	tests := map[string]int{
+		"/billing/golte_/entries/a.js": 200,
+		"/golte_/entries/a.js":         200,
+		"/billing/page":                404,
+	}
+
+	for path, status := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != status {
+			t.Errorf("%s: expected status %d, got %d", path, status, rec.Code)
+		}
+		if status == 200 && rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("%s: expected CORS header for asset origin", path)
+		}
+	}
+}
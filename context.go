@@ -1,7 +1,9 @@
 package golte
 
 import (
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/nichady/golte/render"
 )
@@ -14,9 +16,21 @@ type RenderContext struct {
 	Renderer   *render.Renderer
 	Components []render.Entry
 	ErrPage    string
+	Head       []render.HeadTag
+	// TemplateData is available to template.html as .Data.
+	TemplateData map[string]any
+	// Nonce is the Content-Security-Policy nonce of the response. See [SetNonce].
+	Nonce string
 
-	csr    bool
-	scdata render.SvelteContextData
+	errPages []statusErrPage
+	req      *http.Request
+	onError  RenderErrorHandler
+	logger   *slog.Logger
+	logAttrs []slog.Attr
+	csr      bool
+	rendered bool
+	csp      string
+	scdata   render.SvelteContextData
 }
 
 // GetRenderContext returns the render context from the request, or nil if it doesn't exist.
@@ -41,10 +55,51 @@ func MustGetRenderContext(r *http.Request) *RenderContext {
 
 // Render renders all the components in the render context to the writer,
 // with each subsequent component being a child of the previous.
+// Rendering is stopped if the context of the request is done.
+// If rendering fails, the error is passed to the render error handler.
 func (r *RenderContext) Render(w http.ResponseWriter) {
-	data := render.RenderData{Entries: r.Components, ErrPage: r.ErrPage, SCData: r.scdata}
-	err := r.Renderer.Render(w, data, r.csr)
+	r.render(r.req, w)
+}
+
+// statusErrPage is an error component for a range of status codes.
+type statusErrPage struct {
+	min, max int
+	comp     string
+}
+
+// ErrPageFor returns the error component for the status code.
+// An error component registered for the exact status code is preferred, then the narrowest range containing it.
+// If none match, [RenderContext.ErrPage] is returned.
+func (r *RenderContext) ErrPageFor(status int) string {
+	comp := r.ErrPage
+	width := -1
+	for _, e := range r.errPages {
+		if status < e.min || status > e.max {
+			continue
+		}
+		if w := e.max - e.min; width == -1 || w <= width {
+			comp, width = e.comp, w
+		}
+	}
+	return comp
+}
+
+func (r *RenderContext) render(req *http.Request, w http.ResponseWriter) {
+	// the error page is rendered in place of a component that throws, which results in a 500
+	errPage := r.ErrPageFor(http.StatusInternalServerError)
+	data := render.RenderData{Entries: r.Components, ErrPage: errPage, SCData: r.scdata, Head: r.Head, TemplateData: r.TemplateData, Nonce: r.Nonce, LogAttrs: r.logAttrs}
+	r.rendered = true
+	r.setCSP(w)
+	err := r.Renderer.Render(req.Context(), w, data, r.csr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		r.onError(w, req, err)
+	}
+}
+
+// setCSP sets the Content-Security-Policy header if it is configured, with the nonce of the response.
+func (r *RenderContext) setCSP(w http.ResponseWriter) {
+	if r.csp == "" {
+		return
 	}
+	w.Header().Set("Content-Security-Policy", strings.ReplaceAll(r.csp, "{nonce}", r.Nonce))
 }
@@ -0,0 +1,29 @@
+package golte
+
+import "testing"
+
+func TestErrPageFor(t *testing.T) {
+	rctx := &RenderContext{
+		ErrPage: "error/default",
+		errPages: []statusErrPage{
+			{min: 400, max: 499, comp: "error/client"},
+			{min: 404, max: 404, comp: "error/notfound"},
+			{min: 500, max: 599, comp: "error/server"},
+			{min: 500, max: 599, comp: "error/oops"},
+		},
+	}
+
+	tests := map[int]string{
+		200: "error/default",
+		401: "error/client",
+		404: "error/notfound",
+		500: "error/oops",
+		503: "error/oops",
+	}
+
+	for status, expected := range tests {
+		if comp := rctx.ErrPageFor(status); comp != expected {
+			t.Errorf("%d: expected %q, got %q", status, expected, comp)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package golte
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/nichady/golte/render"
+)
+
+// RenderErrorHandler is called when rendering fails, for example when a component throws outside of
+// an error boundary, the render times out, or the component does not exist.
+// The error is usually one of [*render.Error], [*render.TimeoutError], or [*render.StreamError].
+type RenderErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// DefaultRenderErrorHandler logs the error and renders the error page of the request with status 500.
+// The error message is only passed to the error page in development builds;
+// in production builds, the message is "Internal Server Error".
+// If the error page fails to render as well, a static error page is written instead.
+// If the response was already streamed, the renderer has written the error page into it, so nothing else is written.
+func DefaultRenderErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	rctx := MustGetRenderContext(r)
+	rctx.logger.LogAttrs(r.Context(), slog.LevelError, "render failed", append([]slog.Attr{slog.Any("error", err)}, rctx.logAttrs...)...)
+
+	var serr *render.StreamError
+	if errors.As(err, &serr) {
+		return
+	}
+
+	message := http.StatusText(http.StatusInternalServerError)
+	if rctx.Renderer.Dev() {
+		message = err.Error()
+		var rerr *render.Error
+		if errors.As(err, &rerr) {
+			message += "\n" + rerr.StackTrace()
+		}
+	}
+
+	renderInternalError(w, r, rctx, message)
+}
+
+// handlePanic logs a recovered panic and renders the error page of the request with status 500.
+// The panic value and stack are only passed to the error page in development builds.
+// If the page was already rendered, the panic is only logged.
+func handlePanic(w http.ResponseWriter, r *http.Request, rctx *RenderContext, v any) {
+	stack := string(debug.Stack())
+	attrs := append([]slog.Attr{slog.Any("panic", v), slog.String("stack", stack)}, rctx.logAttrs...)
+	rctx.logger.LogAttrs(r.Context(), slog.LevelError, "panic while handling request", attrs...)
+
+	if rctx.rendered {
+		return
+	}
+
+	message := http.StatusText(http.StatusInternalServerError)
+	if rctx.Renderer.Dev() {
+		message = fmt.Sprintf("panic: %v\n\n%s", v, stack)
+	}
+
+	renderInternalError(w, r, rctx, message)
+}
+
+// renderInternalError renders only the error page of the request with status 500.
+// If the error page fails to render, a static error page is written instead.
+func renderInternalError(w http.ResponseWriter, r *http.Request, rctx *RenderContext, message string) {
+	scdata := rctx.scdata
+	scdata.Page.Status = http.StatusInternalServerError
+
+	data := render.RenderData{
+		Entries: []render.Entry{{
+			Comp:  rctx.ErrPageFor(http.StatusInternalServerError),
+			Props: errorProps(message, http.StatusInternalServerError, nil),
+		}},
+		ErrPage:      render.DefaultErrPage,
+		SCData:       scdata,
+		TemplateData: rctx.TemplateData,
+		Nonce:        rctx.Nonce,
+		LogAttrs:     rctx.logAttrs,
+	}
+
+	rctx.setCSP(w)
+	err := rctx.Renderer.Render(r.Context(), w, data, rctx.csr)
+	if err != nil {
+		writeStaticError(w)
+	}
+}
+
+// writeStaticError writes a minimal error page that doesn't depend on rendering.
+func writeStaticError(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(staticErrorPage))
+}
+
+const staticErrorPage = `<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<title>500 Internal Server Error</title>
+	</head>
+	<body>
+		<h1>500</h1>
+		<p>Internal Server Error</p>
+	</body>
+</html>
+`
@@ -1,33 +1,148 @@
 package golte
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"io/fs"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-// fileServer is a wrapper around http.FileServer that won't do directory listing
-func fileServer(fsys fs.FS) http.Handler {
-	return http.FileServer(wrapperfs{http.FS(fsys)})
+// immutableDirs contain the files of the client build whose names include a hash of their content.
+var immutableDirs = map[string]bool{"entries": true, "chunks": true, "assets": true}
+
+// encodings are the precompressed variants of files that are written by the build, in order of preference.
+var encodings = []struct{ name, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// fileServer serves the files of the client build without directory listing.
+// Content-hashed files are cached indefinitely, every file has a strong ETag,
+// and precompressed variants are served to clients that accept them.
+type fileServer struct {
+	fsys  fs.FS
+	etags sync.Map // file name -> etag
+	// cors allows any origin to load the files, for when they are served through a CDN.
+	cors bool
 }
 
-type wrapperfs struct {
-	fsys http.FileSystem
+func newFileServer(fsys fs.FS) *fileServer {
+	return &fileServer{fsys: fsys}
 }
 
-func (w wrapperfs) Open(name string) (http.File, error) {
-	file, err := w.fsys.Open(name)
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, encoding := name, ""
+	for _, e := range encodings {
+		if !acceptsEncoding(r, e.name) {
+			continue
+		}
+		if variant, err := fs.Stat(s.fsys, name+e.ext); err == nil && !variant.IsDir() {
+			file, encoding = name+e.ext, e.name
+			break
+		}
+	}
+
+	f, err := s.fsys.Open(file)
 	if err != nil {
-		return nil, err
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
 	}
+	defer f.Close()
 
-	info, err := file.Stat()
+	content, etag, err := s.content(file, f)
 	if err != nil {
-		return nil, err
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Add("Vary", "Accept-Encoding")
+	if s.cors {
+		h.Set("Access-Control-Allow-Origin", "*")
+	}
+	if dir, _, ok := strings.Cut(name, "/"); ok && immutableDirs[dir] {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		h.Set("Cache-Control", "no-cache")
 	}
 
-	if info.IsDir() {
-		return nil, fs.ErrNotExist
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+		// the type would otherwise be detected from the compressed content
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		h.Set("Content-Type", ctype)
 	}
 
-	return file, nil
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// content returns the content of the file along with its ETag, which is computed once per file.
+func (s *fileServer) content(name string, f fs.File) (io.ReadSeeker, string, error) {
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return nil, "", err
+		}
+		content = bytes.NewReader(b)
+	}
+
+	if etag, ok := s.etags.Load(name); ok {
+		return content, etag.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return nil, "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, etag)
+	return content, etag, nil
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of the request allows the encoding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+				continue
+			}
+
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,77 @@
+package golte
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileServer(t *testing.T) {
+	s := newFileServer(fstest.MapFS{
+		"entries/app-1234.js":    {Data: []byte("console.log(1)")},
+		"entries/app-1234.js.br": {Data: []byte("br")},
+		"entries/app-1234.js.gz": {Data: []byte("gz")},
+		"favicon.ico":            {Data: []byte("ico")},
+	})
+
+	serve := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		headers      map[string]string
+		status       int
+		body         string
+		encoding     string
+		cacheControl string
+	}{
+		{"identity", "/entries/app-1234.js", nil, 200, "console.log(1)", "", "public, max-age=31536000, immutable"},
+		{"brotli", "/entries/app-1234.js", map[string]string{"Accept-Encoding": "gzip, br"}, 200, "br", "br", "public, max-age=31536000, immutable"},
+		{"gzip", "/entries/app-1234.js", map[string]string{"Accept-Encoding": "gzip, br;q=0"}, 200, "gz", "gzip", "public, max-age=31536000, immutable"},
+		{"not hashed", "/favicon.ico", map[string]string{"Accept-Encoding": "gzip, br"}, 200, "ico", "", "no-cache"},
+		{"directory", "/entries", nil, 404, "", "", ""},
+		{"missing", "/missing.js", nil, 404, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.path, tt.headers)
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.status != 200 {
+				return
+			}
+
+			if body := rec.Body.String(); body != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, body)
+			}
+			if enc := rec.Header().Get("Content-Encoding"); enc != tt.encoding {
+				t.Errorf("expected encoding %q, got %q", tt.encoding, enc)
+			}
+			if cc := rec.Header().Get("Cache-Control"); cc != tt.cacheControl {
+				t.Errorf("expected Cache-Control %q, got %q", tt.cacheControl, cc)
+			}
+			if ct := rec.Header().Get("Content-Type"); tt.encoding != "" && ct != "text/javascript; charset=utf-8" {
+				t.Errorf("unexpected Content-Type %q", ct)
+			}
+			if rec.Header().Get("Vary") != "Accept-Encoding" || rec.Header().Get("ETag") == "" {
+				t.Errorf("unexpected headers %v", rec.Header())
+			}
+
+			etag := rec.Header().Get("ETag")
+			if rec := serve(tt.path, map[string]string{"If-None-Match": etag, "Accept-Encoding": tt.headers["Accept-Encoding"]}); rec.Code != http.StatusNotModified {
+				t.Errorf("expected status 304 for matching ETag, got %d", rec.Code)
+			}
+		})
+	}
+}
@@ -2,9 +2,11 @@ package golte
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"io/fs"
+	"log/slog"
 	"net/http"
-	"strings"
 
 	"github.com/nichady/golte/render"
 )
@@ -20,7 +22,25 @@ type Props = map[string]any
 // It will allow you to use [Layout], [AddLayout], [Page], and [RenderPage].
 // It should be mounted on the root of your router.
 // The middleware should not be mounted on routes other than the root.
-func New(fsys fs.FS) func(http.Handler) http.Handler {
+// If the app is served under a path prefix, use [WithBasePath].
+//
+// The middleware also serves the assets of the build under [AssetPrefix], before the request reaches the next handler.
+// To mount them yourself instead, use [WithoutAssetServing] and [AssetHandler].
+//
+// Panics in the handlers after the middleware are recovered. They are logged with their stack,
+// and the error page for status 500 is rendered unless a page was already rendered.
+// [http.ErrAbortHandler] is not recovered.
+//
+// Options can be passed to configure rendering; see [Option].
+func New(fsys fs.FS, opts ...Option) func(http.Handler) http.Handler {
+	o := options{
+		onRenderError: DefaultRenderErrorHandler,
+		logger:        slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	serverDir, err := fs.Sub(fsys, "server")
 	if err != nil {
 		panic(err)
@@ -31,34 +51,88 @@ func New(fsys fs.FS) func(http.Handler) http.Handler {
 		panic(err)
 	}
 
-	renderer := render.New(serverDir)
-	assets := http.StripPrefix("/"+renderer.Assets()+"/", fileServer(clientDir))
+	renderer := render.New(serverDir, o.render...)
+	assets := newAssetHandler(clientDir, renderer.Assets(), renderer.BasePath(), renderer.AssetOrigin() != "")
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if strings.HasPrefix(r.URL.Path, "/"+renderer.Assets()+"/") {
-				assets.ServeHTTP(w, r)
+			if !o.noAssets && assets.serve(w, r) {
 				return
 			}
 
-			scheme := "http"
-			if r.TLS != nil {
-				scheme += "s"
-			}
-
-			ctx := context.WithValue(r.Context(), contextKey{}, &RenderContext{
+			url := o.requestURL(r)
+			rctx := &RenderContext{
 				Renderer: renderer,
-				ErrPage:  "$$$GOLTE_DEFAULT_ERROR$$$",
+				ErrPage:  render.DefaultErrPage,
 
-				csr: r.Header["Golte"] != nil,
+				onError:  o.onRenderError,
+				logger:   o.logger,
+				logAttrs: logAttrs(r, url),
+				csr:      r.Header["Golte"] != nil,
+				csp:      o.csp,
 				scdata: render.SvelteContextData{
-					URL: scheme + "://" + r.Host + r.URL.String(),
+					URL:  url,
+					Page: pageData(r, o.pageHeaders, o.pageCookies),
 				},
-			})
-			next.ServeHTTP(w, r.WithContext(ctx))
+			}
+
+			if o.nonce {
+				rctx.Nonce = newNonce()
+			}
+
+			defer func() {
+				if v := recover(); v != nil {
+					if v == http.ErrAbortHandler {
+						panic(v)
+					}
+					handlePanic(w, rctx.req, rctx, v)
+				}
+			}()
+
+			rctx.req = r.WithContext(context.WithValue(r.Context(), contextKey{}, rctx))
+			next.ServeHTTP(w, rctx.req)
 		})
 	}
 }
 
+// pageData returns the initial page data of the request, with the allowed headers and cookies.
+func pageData(r *http.Request, headers, cookies []string) render.PageData {
+	page := render.PageData{Status: http.StatusOK}
+
+	for _, name := range headers {
+		if v := r.Header.Get(name); v != "" {
+			if page.Headers == nil {
+				page.Headers = map[string]string{}
+			}
+			page.Headers[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+
+	for _, name := range cookies {
+		if c, err := r.Cookie(name); err == nil {
+			if page.Cookies == nil {
+				page.Cookies = map[string]string{}
+			}
+			page.Cookies[name] = c.Value
+		}
+	}
+
+	return page
+}
+
+// logAttrs returns the attributes that identify the request in logs.
+func logAttrs(r *http.Request, url string) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("url", url),
+	}
+
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+
+	return attrs
+}
+
 // Layout returns a middleware that calls [AddLayout].
 // Use this when there are no props needed to render the component.
 // If you need to pass props, use [AddLayout] instead.
@@ -71,6 +145,18 @@ func Layout(component string) func(http.Handler) http.Handler {
 	}
 }
 
+// LayoutWithError returns a middleware that calls [AddLayoutWithError].
+// Use this when there are no props needed to render the component.
+// If you need to pass props, use [AddLayoutWithError] instead.
+func LayoutWithError(component, errComponent string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			AddLayoutWithError(r, component, nil, errComponent)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Error returns a middleware that calls [SetError].
 // Use this when there are no props needed to render the component.
 // If you need to pass props, use [SetError] instead.
@@ -83,6 +169,21 @@ func Error(component string) func(http.Handler) http.Handler {
 	}
 }
 
+// ErrorFor returns a middleware that calls [SetErrorFor].
+func ErrorFor(status int, component string) func(http.Handler) http.Handler {
+	return ErrorForRange(status, status, component)
+}
+
+// ErrorForRange returns a middleware that calls [SetErrorForRange].
+func ErrorForRange(min, max int, component string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			SetErrorForRange(r, min, max, component)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Page returns a handler that calls [RenderPage].
 // Use this when there are no props needed to render the component.
 // If you need to pass props, use [RenderPage] instead.
@@ -103,13 +204,114 @@ func AddLayout(r *http.Request, component string, props Props) {
 	})
 }
 
+// AddLayoutWithError is like [AddLayout], but also sets an error boundary for the components below the layout.
+// If any of them throws an error while rendering, errComponent is rendered in its place, keeping this layout
+// and the layouts above it intact. Errors thrown by the layout itself are handled by the boundary above it,
+// or the error page of the request if there is none.
+// Like error pages, errComponent receives the "message" and "status" props.
+func AddLayoutWithError(r *http.Request, component string, props Props, errComponent string) {
+	rctx := MustGetRenderContext(r)
+	rctx.Components = append(rctx.Components, render.Entry{
+		Comp:    component,
+		Props:   props,
+		ErrPage: errComponent,
+	})
+}
+
 // SetError sets the error page for the request.
 // Errors consist of any components that take the "message" and "status" props.
 // Calling this multiple times on the same request will overrite the previous error page.
+// It is used for every status code that doesn't have an error page set with [SetErrorFor].
 func SetError(r *http.Request, component string) {
 	MustGetRenderContext(r).ErrPage = component
 }
 
+// SetErrorFor sets the error page for a specific status code.
+// It takes precedence over the error page set with [SetError], which is used as the fallback.
+// The error page for 500 is also rendered when a component throws an error during rendering.
+func SetErrorFor(r *http.Request, status int, component string) {
+	SetErrorForRange(r, status, status, component)
+}
+
+// SetErrorForRange is like [SetErrorFor], but sets the error page for every status code from min to max inclusive,
+// such as 400 to 499. If several ranges contain a status code, the narrowest one is used.
+// Calling this again with the same range will overwrite the previous error page.
+func SetErrorForRange(r *http.Request, min, max int, component string) {
+	rctx := MustGetRenderContext(r)
+	rctx.errPages = append(rctx.errPages, statusErrPage{min: min, max: max, comp: component})
+}
+
+// SetRoute sets the route pattern in the page store, such as "/user/{id}".
+// Use this with routers that expose the matched pattern.
+func SetRoute(r *http.Request, pattern string) {
+	MustGetRenderContext(r).scdata.Page.Route = pattern
+}
+
+// SetParams sets the route parameters in the page store.
+// Use this with routers that expose path parameters, for example:
+//
+//	golte.SetParams(r, mux.Vars(r)) // gorilla/mux
+//	golte.SetParams(r, map[string]string{"id": chi.URLParam(r, "id")}) // chi
+func SetParams(r *http.Request, params map[string]string) {
+	MustGetRenderContext(r).scdata.Page.Params = params
+}
+
+// SetStatus sets the status code of the response, which is written when the page is rendered.
+// It is also available to components in the page store.
+// If a component throws an error while rendering, the status code is 500 instead.
+func SetStatus(r *http.Request, status int) {
+	MustGetRenderContext(r).scdata.Page.Status = status
+}
+
+// SetContext sets a value that every component can access with getContext(key).
+// The value is wrapped in a readable store, which is updated when navigating on the client.
+// The value must be JSON-serializable.
+//
+// Since svelte contexts are created along with the root component, keys that are not set on the first page
+// that is loaded won't be available after client side navigation. Set keys consistently, for example in a middleware.
+func SetContext(r *http.Request, key string, value any) {
+	rctx := MustGetRenderContext(r)
+	if rctx.scdata.Context == nil {
+		rctx.scdata.Context = map[string]any{}
+	}
+	rctx.scdata.Context[key] = value
+}
+
+// SetTemplateData sets a value that is available to template.html as .Data, for example:
+//
+//	golte.SetTemplateData(r, "lang", "en")
+//
+// can be used as <html lang="{{ .Data.lang }}">. The template is executed with html/template,
+// so values are escaped according to their context and can safely come from the request.
+// Functions can be added to the template with [WithTemplateFuncs].
+func SetTemplateData(r *http.Request, key string, value any) {
+	rctx := MustGetRenderContext(r)
+	if rctx.TemplateData == nil {
+		rctx.TemplateData = map[string]any{}
+	}
+	rctx.TemplateData[key] = value
+}
+
+// SetNonce sets the Content-Security-Policy nonce of the response, for example when it is generated by another middleware.
+// It replaces the nonce generated with [WithNonce].
+func SetNonce(r *http.Request, nonce string) {
+	MustGetRenderContext(r).Nonce = nonce
+}
+
+// Nonce returns the Content-Security-Policy nonce of the response, or an empty string if there is none.
+func Nonce(r *http.Request) string {
+	return MustGetRenderContext(r).Nonce
+}
+
+// newNonce returns a random nonce.
+func newNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
 // RenderPage renders the specified component.
 // If any layouts were added previously, then each subsequent layout will
 // go in the <slot> of the previous layout. The page will be in the <slot>
@@ -120,27 +322,35 @@ func RenderPage(w http.ResponseWriter, r *http.Request, component string, props
 		Comp:  component,
 		Props: props,
 	})
-	rctx.Render(w)
+	rctx.render(r, w)
 }
 
-// RenderError renders the current error page along with layouts.
+// RenderError renders the error page for the status code along with layouts.
+// See [SetError] and [SetErrorFor] for how the error page is chosen.
 // The error componenet will receive "message" and "status" as props.
-// It will also write the status code to the header.
+// It will also write the status code to the header, overriding any status set with [SetStatus].
 func RenderError(w http.ResponseWriter, r *http.Request, message string, status int) {
-	rctx := MustGetRenderContext(r)
-	entry := render.Entry{Comp: rctx.ErrPage, Props: Props{
-		"message": message,
-		"status":  status,
-	}}
-	rctx.Components = append(rctx.Components, entry)
-	rctx.Render(respWriterWrapper{w})
+	RenderErrorProps(w, r, message, status, nil)
 }
 
-// respWriterWrapper is needed to prevent superfluous WriteHeader calls
-type respWriterWrapper struct {
-	http.ResponseWriter
+// RenderErrorProps is like [RenderError], but also passes extra props to the error component,
+// such as a request ID or a list of validation problems.
+// The "message" and "status" props take precedence over props with the same name.
+func RenderErrorProps(w http.ResponseWriter, r *http.Request, message string, status int, props Props) {
+	rctx := MustGetRenderContext(r)
+	rctx.scdata.Page.Status = status
+	entry := render.Entry{Comp: rctx.ErrPageFor(status), Props: errorProps(message, status, props)}
+	rctx.Components = append(rctx.Components, entry)
+	rctx.render(r, w)
 }
 
-func (w respWriterWrapper) WriteHeader(int) {
-	w.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+// errorProps returns the props of an error component.
+func errorProps(message string, status int, props Props) Props {
+	merged := make(Props, len(props)+2)
+	for k, v := range props {
+		merged[k] = v
+	}
+	merged["message"] = message
+	merged["status"] = status
+	return merged
 }
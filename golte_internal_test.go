@@ -0,0 +1,45 @@
+package golte
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/nichady/golte/render"
+)
+
+func TestPageData(t *testing.T) {
+	var o options
+	WithPageHeaders("accept-language", "X-Missing")(&o)
+	WithPageCookies("theme", "missing")(&o)
+
+	r := httptest.NewRequest("GET", "/user/123", nil)
+	r.Header.Set("Accept-Language", "en")
+	r.Header.Set("Authorization", "secret")
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	r.AddCookie(&http.Cookie{Name: "session", Value: "secret"})
+
+	rctx := &RenderContext{scdata: render.SvelteContextData{Page: pageData(r, o.pageHeaders, o.pageCookies)}}
+	r = r.WithContext(context.WithValue(r.Context(), contextKey{}, rctx))
+
+	SetRoute(r, "/user/{id}")
+	SetParams(r, map[string]string{"id": "123"})
+
+	expected := render.PageData{
+		Status:  http.StatusOK,
+		Route:   "/user/{id}",
+		Params:  map[string]string{"id": "123"},
+		Headers: map[string]string{"Accept-Language": "en"},
+		Cookies: map[string]string{"theme": "dark"},
+	}
+	if !reflect.DeepEqual(rctx.scdata.Page, expected) {
+		t.Errorf("expected %+v, got %+v", expected, rctx.scdata.Page)
+	}
+
+	// nothing is exposed unless it is allowed
+	if page := pageData(r, nil, nil); page.Headers != nil || page.Cookies != nil {
+		t.Errorf("unexpected page data %+v", page)
+	}
+}
@@ -1,6 +1,7 @@
 package golte_test
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -47,6 +48,48 @@ func TestMain(m *testing.M) {
 		golte.RenderError(w, r, "mymessage", 401)
 	})))
 
+	mux.Handle("/error2", e0(golte.ErrorFor(404, "error/1")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		golte.RenderError(w, r, "notfound", 404)
+	}))))
+
+	mux.Handle("/error3", golte.Error("error/2")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		golte.RenderErrorProps(w, r, "mymessage", 422, golte.Props{"hint": "myhint", "status": 200})
+	})))
+
+	mux.Handle("/error4", e1(golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("wrapped: %w", &golte.HTTPError{Status: 403, Message: "forbidden"})
+	})))
+	mux.Handle("/error5", e1(golte.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("internal")
+	})))
+
+	mux.Handle("/boundary", e0(golte.LayoutWithError("layout/0", "error/1")(l2(l3(p1)))))
+
+	mux.Handle("/panic", e1(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})))
+
+	mux.Handle("/head", e0(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		golte.SetTitle(r, "mytitle")
+		golte.AddMeta(r, map[string]string{"name": "description", "content": "mydescription"})
+		golte.RenderPage(w, r, "page/1", nil)
+	})))
+
+	mux.Handle("/hostile", e0(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		golte.SetContext(r, "hostile", hostile)
+		golte.RenderPage(w, r, "page/5", map[string]any{"value": hostile})
+	})))
+
+	mux.Handle("/status", e0(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		golte.SetStatus(r, 404)
+		golte.RenderPage(w, r, "page/1", nil)
+	})))
+
+	mux.Handle("/context", e0(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		golte.SetContext(r, "value", "myvalue")
+		golte.RenderPage(w, r, "page/4", nil)
+	})))
+
 	server = httptest.NewServer(golte.New(testdata.App)(mux))
 	defer server.Close()
 
@@ -61,6 +104,9 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+// hostile tries to break out of the hydration script.
+const hostile = "</script><script>window.pwned=true</script><!--<script>\u2028\u2029&amp;</SCRIPT>\"'`"
+
 func checkProps(el *rod.Element, propMap map[string]any) func(*testing.T) {
 	return func(t *testing.T) {
 		ch := make(chan *rod.Element)
@@ -291,6 +337,194 @@ func TestErrorPage(t *testing.T) {
 				"message": "mymessage",
 			}))
 		})
+
+		t.Run("check status error", func(t *testing.T) {
+			page := browser.MustPage(server.URL + "/error2")
+			page.MustWaitLoad()
+			page.MustWaitStable()
+
+			if !page.MustHas("#error1") || page.MustHas("#error0") {
+				t.FailNow()
+			}
+
+			t.Run("check props", checkProps(page.MustElement("#error1"), map[string]any{
+				"status":  404,
+				"message": "notfound",
+			}))
+		})
+
+		t.Run("check handler error", func(t *testing.T) {
+			page := browser.MustPage(server.URL + "/error4")
+			page.MustWaitLoad()
+			page.MustWaitStable()
+
+			if !page.MustHas("#error1") {
+				t.FailNow()
+			}
+
+			t.Run("check props", checkProps(page.MustElement("#error1"), map[string]any{
+				"status":  403,
+				"message": "forbidden",
+			}))
+		})
+
+		t.Run("check error boundary", func(t *testing.T) {
+			page := browser.MustPage(server.URL + "/boundary")
+			page.MustWaitLoad()
+			page.MustWaitStable()
+
+			if !page.MustHas("#layout0 > #layout2 > #error1") {
+				t.FailNow()
+			}
+
+			if page.MustHas("#error0") || page.MustHas("#layout3") || page.MustHas("#page1") {
+				t.Fail()
+			}
+
+			t.Run("check props", checkProps(page.MustElement("#error1"), map[string]any{
+				"status": 500,
+			}))
+		})
+
+		t.Run("check panic", func(t *testing.T) {
+			page := browser.MustPage(server.URL + "/panic")
+			page.MustWaitLoad()
+			page.MustWaitStable()
+
+			if !page.MustHas("#error1") {
+				t.FailNow()
+			}
+
+			t.Run("check props", checkProps(page.MustElement("#error1"), map[string]any{
+				"status": 500,
+			}))
+		})
+
+		t.Run("check error props", func(t *testing.T) {
+			page := browser.MustPage(server.URL + "/error3")
+			page.MustWaitLoad()
+			page.MustWaitStable()
+
+			if !page.MustHas("#error2") {
+				t.FailNow()
+			}
+
+			t.Run("check props", checkProps(page.MustElement("#error2"), map[string]any{
+				"status":  422,
+				"message": "mymessage",
+				"hint":    "myhint",
+			}))
+		})
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	err := rod.Try(func() {
+		page := browser.MustPage(server.URL + "/context")
+		page.MustWaitLoad()
+		page.MustWaitStable()
+
+		if !page.MustHas("#page4") {
+			t.FailNow()
+		}
+
+		t.Run("check value", checkProps(page.MustElement("#page4"), map[string]any{
+			"value": "myvalue",
+		}))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := map[string]int{
+		"/route0": 200,
+		"/status": 404,
+		"/error0": 500,
+		"/error1": 401,
+		"/error3": 422,
+		"/error4": 403,
+		"/error5": 500,
+		"/panic":  500,
+	}
+
+	for path, status := range tests {
+		for _, csr := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s csr=%v", path, csr), func(t *testing.T) {
+				req, err := http.NewRequest("GET", server.URL+path, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if csr {
+					req.Header.Set("Golte", "true")
+				}
+
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+
+				// errors thrown by components are only detected when rendering on the server
+				want := status
+				if csr && path == "/error0" {
+					want = 200
+				}
+
+				if resp.StatusCode != want {
+					t.Errorf("expected status %d, instead got %d", want, resp.StatusCode)
+				}
+			})
+		}
+	}
+}
+
+func TestHead(t *testing.T) {
+	err := rod.Try(func() {
+		page := browser.MustPage(server.URL + "/head")
+		page.MustWaitLoad()
+
+		if title := page.MustInfo().Title; title != "mytitle" {
+			t.Errorf("expected title mytitle, instead got %s", title)
+		}
+
+		meta := page.MustElement(`head > meta[name="description"][data-golte-head]`)
+		if content := meta.MustAttribute("content"); content == nil || *content != "mydescription" {
+			t.Errorf("unexpected description %v", content)
+		}
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHostileProps(t *testing.T) {
+	err := rod.Try(func() {
+		page := browser.MustPage(server.URL + "/hostile")
+		page.MustWaitLoad()
+		page.MustWaitStable()
+
+		if page.MustEval("() => window.pwned === true").Bool() {
+			t.Fatal("injected script was executed")
+		}
+
+		if n := len(page.MustElements("#page5")); n != 1 {
+			t.Fatalf("expected one #page5, instead got %d", n)
+		}
+
+		t.Run("check props", checkProps(page.MustElement("#page5"), map[string]any{
+			"value":   hostile,
+			"context": hostile,
+			"mounted": true, // hydration succeeded
+		}))
+
+		if text := page.MustElement("#text").MustText(); text != hostile {
+			t.Errorf("expected text %q, instead got %q", hostile, text)
+		}
 	})
 	if err != nil {
 		t.Error(err)
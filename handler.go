@@ -0,0 +1,78 @@
+package golte
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// HandlerFunc is an adapter to use functions that return an error as an [http.Handler].
+// If the function returns an error, it is rendered through the error page of the request.
+//
+// An [*HTTPError] is rendered with its status, message and props.
+// Any other error is logged and rendered with status 500. Its message is only passed to the error page
+// in development builds; in production builds, the message is "Internal Server Error".
+// Errors that are returned after a page was rendered are only logged.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls f(w, r) and renders the returned error, if any.
+// If a page was already rendered, the error is only logged.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := f(w, r)
+	if err == nil {
+		return
+	}
+
+	rctx := MustGetRenderContext(r)
+	var herr *HTTPError
+	isHTTP := errors.As(err, &herr)
+
+	if rctx.rendered || !isHTTP {
+		rctx.logger.LogAttrs(r.Context(), slog.LevelError, "handler failed", append([]slog.Attr{slog.Any("error", err)}, rctx.logAttrs...)...)
+	}
+
+	// the response was already written, so the error page can't be rendered
+	if rctx.rendered {
+		return
+	}
+
+	if isHTTP {
+		RenderErrorProps(w, r, herr.message(), herr.status(), herr.Props)
+		return
+	}
+
+	message := http.StatusText(http.StatusInternalServerError)
+	if rctx.Renderer.Dev() {
+		message = err.Error()
+	}
+	RenderError(w, r, message, http.StatusInternalServerError)
+}
+
+// HTTPError is an error that is rendered through the error page by [HandlerFunc].
+// Its message is shown to the user, so it should not contain internal details.
+type HTTPError struct {
+	// Status is the status code of the response. If it is 0, 500 is used.
+	Status int
+	// Message is passed to the error page. If it is empty, the status text is used, such as "Not Found".
+	Message string
+	// Props are extra props that are passed to the error page; see [RenderErrorProps].
+	Props Props
+}
+
+func (e *HTTPError) Error() string {
+	return e.message()
+}
+
+func (e *HTTPError) status() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
+
+func (e *HTTPError) message() string {
+	if e.Message == "" {
+		return http.StatusText(e.status())
+	}
+	return e.Message
+}
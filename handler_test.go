@@ -0,0 +1,49 @@
+package golte
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testBuild is a minimal build whose components render their name.
+var testBuild = fstest.MapFS{
+	"server/template.html": {Data: []byte(`<html><head>{{ .Head }}</head><body>{{ .Body }}</body></html>`)},
+	"server/info.js":       {Data: []byte(`exports.Assets = "golte_";`)},
+	"server/render.js": {Data: []byte(`
+		exports.Manifest = {
+			"page": { Client: "/golte_/page.js", CSS: [] },
+			"$$$GOLTE_DEFAULT_ERROR$$$": { Client: "/golte_/error.js", CSS: [] },
+		};
+		exports.Render = function (entries) {
+			return { Head: "", Body: entries.map((e) => "<p>" + e.Comp + "</p>").join(""), HasError: false };
+		};
+	`)},
+	"client/page.js": {Data: []byte("")},
+}
+
+func TestHandlerFuncAfterRender(t *testing.T) {
+	var logs strings.Builder
+	mw := New(testBuild, WithPoolSize(1), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	h := mw(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		RenderPage(w, r, "page", nil)
+		return &HTTPError{Status: http.StatusNotFound}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); strings.Count(body, "<html>") != 1 || !strings.Contains(body, "<body><p>page</p></body>") {
+		t.Errorf("expected only the page to be rendered, got %q", body)
+	}
+	if !strings.Contains(logs.String(), "handler failed") {
+		t.Errorf("expected the error to be logged, got %q", logs.String())
+	}
+}
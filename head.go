@@ -0,0 +1,77 @@
+package golte
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/nichady/golte/render"
+)
+
+// SetTitle sets the <title> of the page, replacing any title set previously.
+// Elements added from go are inserted into the <head> during server side rendering,
+// and replace the ones of the previous page on client side navigation.
+// The title takes precedence over titles set by components with <svelte:head>.
+func SetTitle(r *http.Request, title string) {
+	addHead(r, render.HeadTag{Tag: "title", Content: title, Key: "title"})
+}
+
+// AddMeta adds a <meta> element with the given attributes to the <head> of the page, for example:
+//
+//	golte.AddMeta(r, map[string]string{"name": "description", "content": "My blog"})
+//	golte.AddMeta(r, map[string]string{"property": "og:title", "content": "My blog"})
+//
+// A meta element with the same name, property or http-equiv as a previous one replaces it,
+// and a meta element with a charset replaces any previous one with a charset, as well as the ones of the template and components.
+func AddMeta(r *http.Request, attrs map[string]string) {
+	var key string
+	for _, name := range []string{"name", "property", "http-equiv"} {
+		if v, ok := attrs[name]; ok {
+			key = "meta " + name + " " + v
+			break
+		}
+	}
+	if _, ok := attrs["charset"]; ok && key == "" {
+		// a document has a single charset, whatever its value
+		key = "meta charset"
+	}
+
+	addHead(r, render.HeadTag{Tag: "meta", Attrs: attrs, Key: key})
+}
+
+// AddLink adds a <link> element with the given attributes to the <head> of the page, for example:
+//
+//	golte.AddLink(r, map[string]string{"rel": "canonical", "href": "https://example.com/blog"})
+//
+// A canonical link replaces any previous one, and other links replace previous ones with the same rel and href.
+func AddLink(r *http.Request, attrs map[string]string) {
+	key := "link " + attrs["rel"]
+	if attrs["rel"] != "canonical" {
+		key += " " + attrs["href"]
+	}
+
+	addHead(r, render.HeadTag{Tag: "link", Attrs: attrs, Key: key})
+}
+
+// AddJSONLD adds a <script type="application/ld+json"> element containing the JSON encoding of value
+// to the <head> of the page. Identical values are only added once.
+func AddJSONLD(r *http.Request, value any) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	// json.Marshal escapes <, > and &, so the content can't close the script element
+	content := string(b)
+	addHead(r, render.HeadTag{
+		Tag:     "script",
+		Attrs:   map[string]string{"type": "application/ld+json"},
+		Content: content,
+		Key:     "jsonld " + content,
+	})
+	return nil
+}
+
+func addHead(r *http.Request, tag render.HeadTag) {
+	rctx := MustGetRenderContext(r)
+	rctx.Head = append(rctx.Head, tag)
+}
@@ -0,0 +1,28 @@
+package golte
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddMetaKey(t *testing.T) {
+	rctx := &RenderContext{}
+	r := httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), contextKey{}, rctx))
+
+	AddMeta(r, map[string]string{"charset": "utf-8"})
+	AddMeta(r, map[string]string{"charset": "iso-8859-1"})
+	AddMeta(r, map[string]string{"name": "description", "content": "a"})
+	AddMeta(r, map[string]string{"name": "keywords", "content": "b"})
+
+	keys := []string{"meta charset", "meta charset", "meta name description", "meta name keywords"}
+	if len(rctx.Head) != len(keys) {
+		t.Fatalf("expected %d tags, got %d", len(keys), len(rctx.Head))
+	}
+	for i, key := range keys {
+		if rctx.Head[i].Key != key {
+			t.Errorf("tag %d: expected key %q, got %q", i, key, rctx.Head[i].Key)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package golte
+
+import (
+	"log/slog"
+	"net/netip"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nichady/golte/render"
+)
+
+// Option configures the middleware returned by [New].
+type Option func(*options)
+
+type options struct {
+	render        []render.Option
+	onRenderError RenderErrorHandler
+	logger        *slog.Logger
+	pageHeaders   []string
+	pageCookies   []string
+
+	origin         string
+	nonce          bool
+	csp            string
+	trustedProxies []netip.Prefix
+
+	// these are also passed to the renderer, but are needed for serving assets without one
+	basePath    string
+	assetOrigin string
+	noAssets    bool
+}
+
+// WithPoolSize sets the number of JS runtimes used for server side rendering.
+// This is the maximum number of pages that can be rendered concurrently.
+// It defaults to [runtime.GOMAXPROCS].
+func WithPoolSize(n int) Option {
+	return func(o *options) {
+		o.render = append(o.render, render.WithPoolSize(n))
+	}
+}
+
+// WithPoolWait sets the maximum time a render will wait for a JS runtime to become available.
+// By default, renders will wait indefinitely.
+func WithPoolWait(d time.Duration) Option {
+	return func(o *options) {
+		o.render = append(o.render, render.WithPoolWait(d))
+	}
+}
+
+// WithRenderTimeout sets the maximum duration of server side rendering.
+// If a render takes longer, it is interrupted and a [*render.TimeoutError] is returned.
+// Renders are also interrupted when the request context is done. By default, there is no timeout.
+func WithRenderTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.render = append(o.render, render.WithTimeout(d))
+	}
+}
+
+// WithStreaming enables streaming of server side rendered pages.
+// The template up to the body, including stylesheet links, is flushed to the client before components are rendered.
+//
+// Elements from <svelte:head>, such as <title> and <meta>, are written into the body instead of the head,
+// where search engines and link previews ignore them. Set them from go with [SetTitle], [AddMeta] and [AddLink],
+// which are still written into the head. See [render.WithStreaming] for the other limitations of streaming mode.
+func WithStreaming() Option {
+	return func(o *options) {
+		o.render = append(o.render, render.WithStreaming(true))
+	}
+}
+
+// WithRenderErrorHandler sets the function that is called when rendering fails.
+// By default, [DefaultRenderErrorHandler] is used.
+func WithRenderErrorHandler(h RenderErrorHandler) Option {
+	return func(o *options) {
+		o.onRenderError = h
+	}
+}
+
+// WithLogger sets the logger used for render errors and for console output of components during server side rendering.
+// Each line is logged with the context of the request and the attributes "method", "url", and "request_id"
+// (from the X-Request-Id header, if present). It defaults to [slog.Default].
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+			o.render = append(o.render, render.WithLogger(logger))
+		}
+	}
+}
+
+// WithPageHeaders sets the request headers that are exposed to components in the page store.
+// By default, no headers are exposed.
+func WithPageHeaders(names ...string) Option {
+	return func(o *options) {
+		o.pageHeaders = append(o.pageHeaders, names...)
+	}
+}
+
+// WithPageCookies sets the request cookies that are exposed to components in the page store.
+// By default, no cookies are exposed.
+func WithPageCookies(names ...string) Option {
+	return func(o *options) {
+		o.pageCookies = append(o.pageCookies, names...)
+	}
+}
+
+// WithOrigin sets the public origin of the app, such as "https://example.com".
+// It is used instead of the host of the request to build the URL that components see.
+// This takes precedence over [WithTrustedProxies].
+func WithOrigin(origin string) Option {
+	return func(o *options) {
+		o.origin = strings.TrimSuffix(origin, "/")
+	}
+}
+
+// WithTrustedProxies sets the proxies whose Forwarded and X-Forwarded-Proto/X-Forwarded-Host headers
+// are trusted when building the URL that components see. Each proxy is an IP address or a CIDR range,
+// such as "10.0.0.0/8". By default, no proxies are trusted and the headers are ignored.
+// It panics if an address is invalid.
+func WithTrustedProxies(proxies ...string) Option {
+	return func(o *options) {
+		for _, proxy := range proxies {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				addr, aerr := netip.ParseAddr(proxy)
+				if aerr != nil {
+					panic(err)
+				}
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+			o.trustedProxies = append(o.trustedProxies, prefix.Masked())
+		}
+	}
+}
+
+// WithBasePath sets the path prefix that the app is served under, such as "/billing".
+// Asset URLs, stylesheet links, and the hydration script are prefixed with it,
+// and client side navigation is limited to links under it.
+// Requests for assets are served both with and without the prefix, and the URL that components see
+// always includes it, so it works whether or not a gateway strips the prefix before forwarding requests.
+func WithBasePath(path string) Option {
+	return func(o *options) {
+		o.render = append(o.render, render.WithBasePath(path))
+		o.basePath = render.CleanBasePath(path)
+	}
+}
+
+// WithTemplateFuncs adds functions that can be called from template.html.
+// See [render.WithFuncs].
+func WithTemplateFuncs(funcs template.FuncMap) Option {
+	return func(o *options) {
+		o.render = append(o.render, render.WithFuncs(funcs))
+	}
+}
+
+// WithNonce generates a random Content-Security-Policy nonce for every request.
+// The nonce is added to the hydration script, the stylesheet links, and the elements added with [AddLink] and [AddJSONLD].
+// It can be read with [Nonce], for example to add it to a header, or replaced with [SetNonce].
+func WithNonce() Option {
+	return func(o *options) {
+		o.nonce = true
+	}
+}
+
+// DefaultContentSecurityPolicy is a strict policy that only allows scripts and stylesheets with the nonce of the response,
+// as well as scripts and stylesheets loaded by them. It can be passed to [WithContentSecurityPolicy].
+const DefaultContentSecurityPolicy = "script-src 'nonce-{nonce}' 'strict-dynamic'; style-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'"
+
+// WithContentSecurityPolicy sets the Content-Security-Policy header of rendered responses to policy,
+// replacing every "{nonce}" with the nonce of the response. It implies [WithNonce].
+func WithContentSecurityPolicy(policy string) Option {
+	return func(o *options) {
+		o.nonce = true
+		o.csp = policy
+	}
+}
+
+// WithExternalHydration writes the hydration data as JSON that is read by an external module,
+// so that no inline script has to be executed. See [render.WithExternalHydration].
+func WithExternalHydration() Option {
+	return func(o *options) {
+		o.render = append(o.render, render.WithExternalHydration(true))
+	}
+}
+
+// WithAssetOrigin serves the assets from another origin, such as a CDN. See [render.WithAssetOrigin].
+// The assets are still served by the middleware, so that a CDN can pull them from the app.
+// In that case, they are sent with "Access-Control-Allow-Origin: *", which the CDN should pass on.
+func WithAssetOrigin(origin string) Option {
+	return func(o *options) {
+		o.render = append(o.render, render.WithAssetOrigin(origin))
+		o.assetOrigin = origin
+	}
+}
+
+// WithoutAssetServing stops the middleware from serving the assets of the build,
+// so that they can be mounted explicitly with [AssetHandler].
+func WithoutAssetServing() Option {
+	return func(o *options) {
+		o.noAssets = true
+	}
+}
@@ -0,0 +1,106 @@
+package golte
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// requestURL returns the URL of the request as seen by the client.
+// If an origin is set, it is used instead of the host of the request.
+// Otherwise, the Forwarded and X-Forwarded-* headers are used if the request comes from a trusted proxy.
+// If the base path was stripped by a gateway, it is added back.
+func (o *options) requestURL(r *http.Request) string {
+	uri := r.URL.RequestURI()
+	if o.basePath != "" && r.URL.Path != o.basePath && !strings.HasPrefix(r.URL.Path, o.basePath+"/") {
+		uri = o.basePath + uri
+	}
+
+	if o.origin != "" {
+		return o.origin + uri
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme += "s"
+	}
+	host := r.Host
+
+	if o.trusted(r) {
+		proto, fhost := forwarded(r.Header)
+		if proto != "" {
+			scheme = proto
+		}
+		if fhost != "" {
+			host = fhost
+		}
+	}
+
+	return scheme + "://" + host + uri
+}
+
+// trusted reports whether the request was sent by a trusted proxy.
+func (o *options) trusted(r *http.Request) bool {
+	if len(o.trustedProxies) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range o.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwarded returns the protocol and host that the client used, according to the proxy headers.
+// The Forwarded header takes precedence over X-Forwarded-Proto and X-Forwarded-Host.
+// Only the last value is used, which is the one added by the trusted proxy.
+// Earlier values were sent by the client or by proxies in front of it, so they can't be trusted.
+func forwarded(h http.Header) (proto, host string) {
+	if element := lastValue(h, "Forwarded"); element != "" {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+
+			value = strings.Trim(value, `"`)
+			switch strings.ToLower(key) {
+			case "proto":
+				proto = strings.ToLower(value)
+			case "host":
+				host = value
+			}
+		}
+		return proto, host
+	}
+
+	return strings.ToLower(lastValue(h, "X-Forwarded-Proto")), lastValue(h, "X-Forwarded-Host")
+}
+
+// lastValue returns the last element of a comma separated header, which may be split over several lines.
+func lastValue(h http.Header, key string) string {
+	values := h.Values(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	v := values[len(values)-1]
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
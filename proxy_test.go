@@ -0,0 +1,62 @@
+package golte
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestURL(t *testing.T) {
+	var proxied options
+	WithTrustedProxies("10.0.0.0/8", "192.168.1.1")(&proxied)
+
+	var pinned options
+	WithOrigin("https://example.com/")(&pinned)
+
+	var prefixed options
+	WithBasePath("/billing")(&prefixed)
+
+	tests := []struct {
+		name     string
+		opts     options
+		remote   string
+		headers  map[string]string
+		expected string
+	}{
+		{"direct", options{}, "10.0.0.1:1234", nil, "http://internal:8080/page?q=1"},
+		{"untrusted headers", options{}, "10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "example.com"}, "http://internal:8080/page?q=1"},
+		{"untrusted proxy", proxied, "172.16.0.1:1234", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "example.com"}, "http://internal:8080/page?q=1"},
+		{"x-forwarded", proxied, "10.1.2.3:1234", map[string]string{"X-Forwarded-Proto": "http, https", "X-Forwarded-Host": "spoofed.com, example.com"}, "https://example.com/page?q=1"},
+		{"forwarded", proxied, "192.168.1.1:1234", map[string]string{"Forwarded": `for=1.2.3.4;proto=http;host="spoofed.com", for=5.6.7.8;proto=https;host="example.com"`, "X-Forwarded-Host": "other.com"}, "https://example.com/page?q=1"},
+		{"origin", pinned, "10.0.0.1:1234", map[string]string{"X-Forwarded-Host": "other.com"}, "https://example.com/page?q=1"},
+		{"stripped base path", prefixed, "10.0.0.1:1234", nil, "http://internal:8080/billing/page?q=1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://internal:8080/page?q=1", nil)
+			r.RemoteAddr = test.remote
+			for k, v := range test.headers {
+				r.Header.Set(k, v)
+			}
+
+			if url := test.opts.requestURL(r); url != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, url)
+			}
+		})
+	}
+
+	// the base path is not added again if it was not stripped
+	r := httptest.NewRequest("GET", "http://internal:8080/billing/page?q=1", nil)
+	if url := prefixed.requestURL(r); url != "http://internal:8080/billing/page?q=1" {
+		t.Errorf("expected the base path to be kept, got %s", url)
+	}
+
+	// proxies may add their value as a separate header line
+	r = httptest.NewRequest("GET", "http://internal:8080/page?q=1", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Add("X-Forwarded-Host", "spoofed.com")
+	r.Header.Add("X-Forwarded-Host", "example.com")
+	if url := proxied.requestURL(r); url != "http://example.com/page?q=1" {
+		t.Errorf("expected the last header line to be used, got %s", url)
+	}
+}
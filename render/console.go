@@ -0,0 +1,52 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/util"
+)
+
+// consoleLevels maps the methods of the console object to log levels.
+var consoleLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"log":   slog.LevelInfo,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// enableConsole adds a console object to the runtime which writes to the logger of the vm.
+// Each line is logged with the context and attributes of the render in progress.
+func (v *vm) enableConsole() {
+	u := util.New(v.runtime)
+	console := v.runtime.NewObject()
+
+	for method, level := range consoleLevels {
+		level := level
+		console.Set(method, func(call goja.FunctionCall) goja.Value {
+			var b bytes.Buffer
+			var format string
+			if arg := call.Argument(0); !goja.IsUndefined(arg) {
+				format = arg.String()
+			}
+
+			var args []goja.Value
+			if len(call.Arguments) > 0 {
+				args = call.Arguments[1:]
+			}
+			u.Format(&b, format, args...)
+
+			ctx := v.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			v.logger.LogAttrs(ctx, level, b.String(), v.attrs...)
+			return goja.Undefined()
+		})
+	}
+
+	v.runtime.Set("console", console)
+}
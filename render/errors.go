@@ -0,0 +1,158 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// TimeoutError is returned when a render is stopped because its context was done,
+// either because the deadline passed or because the context was canceled.
+// The JS runtime that was rendering is interrupted and remains usable for other renders.
+type TimeoutError struct {
+	// Cause is the error of the context, usually [context.DeadlineExceeded] or [context.Canceled].
+	Cause error
+}
+
+func (e *TimeoutError) Error() string {
+	return "golte: render interrupted: " + e.Cause.Error()
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Cause
+}
+
+// StreamError is returned when rendering fails in streaming mode after the response was already started.
+// The status code and the head of the document have been sent, so the renderer writes the error page
+// for status 500 ([RenderData.ErrPage]) into the body and closes the document.
+// If the error page fails to render as well, a static error message is written instead.
+type StreamError struct {
+	Err error
+}
+
+func (e *StreamError) Error() string {
+	return "golte: render failed after response was streamed: " + e.Err.Error()
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// Error is an error thrown by JavaScript while rendering.
+type Error struct {
+	// Component is the name of the component that threw the error, if known.
+	Component string
+	// Message is the message of the JavaScript error.
+	Message string
+	// Stack is the stack trace of the error, with the most recent frame first.
+	// In development builds, positions point to the original .svelte and .ts files,
+	// since the runtime resolves them using the source maps of the build.
+	Stack []Frame
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("golte: ")
+	if e.Component != "" {
+		fmt.Fprintf(&b, "%q: ", e.Component)
+	}
+	b.WriteString(e.Message)
+	if len(e.Stack) > 0 {
+		b.WriteString(" at ")
+		b.WriteString(e.Stack[0].String())
+	}
+	return b.String()
+}
+
+// StackTrace returns the stack trace formatted with one frame per line, like in JavaScript.
+func (e *Error) StackTrace() string {
+	var b strings.Builder
+	for _, f := range e.Stack {
+		b.WriteString("\tat ")
+		b.WriteString(f.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Frame is a single frame of a JavaScript stack trace.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+}
+
+func (f Frame) String() string {
+	if f.Function == "" {
+		return fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	}
+	return fmt.Sprintf("%s (%s:%d:%d)", f.Function, f.File, f.Line, f.Column)
+}
+
+// frameRegexp matches a frame of a goja stack trace, such as "\tat render (web/page/home.svelte:3:5(12))".
+var frameRegexp = regexp.MustCompile(`^\s*at (?:(.+?) \()?(.+):(\d+):(\d+)\(\d+\)\)?$`)
+
+// parseStack parses the frames of a goja stack trace. Lines that aren't frames, such as the message, are skipped.
+func parseStack(stack string) []Frame {
+	var frames []Frame
+	for _, line := range strings.Split(stack, "\n") {
+		m := frameRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		lineNum, _ := strconv.Atoi(m[3])
+		col, _ := strconv.Atoi(m[4])
+		frames = append(frames, Frame{
+			Function: m[1],
+			File:     m[2],
+			Line:     lineNum,
+			Column:   col,
+		})
+	}
+	return frames
+}
+
+// jsError is the description of an error that is created by the server build.
+type jsError struct {
+	Component string
+	Message   string
+	Stack     string
+}
+
+func (e *jsError) toError() *Error {
+	if e == nil {
+		return nil
+	}
+
+	return &Error{
+		Component: e.Component,
+		Message:   e.Message,
+		Stack:     parseStack(e.Stack),
+	}
+}
+
+// exceptionToError converts an exception that was thrown out of the server build into an [*Error].
+func exceptionToError(ex *goja.Exception) *Error {
+	obj, ok := ex.Value().(*goja.Object)
+	if !ok {
+		return &Error{Message: ex.Value().String(), Stack: parseStack(ex.String())}
+	}
+
+	e := jsError{Message: obj.String(), Stack: ex.String()}
+	if v := obj.Get("message"); v != nil && !goja.IsUndefined(v) {
+		e.Message = v.String()
+	}
+	if v := obj.Get("stack"); v != nil && !goja.IsUndefined(v) {
+		e.Stack = v.String()
+	}
+	if v := obj.Get("component"); v != nil && !goja.IsUndefined(v) {
+		e.Component = v.String()
+	}
+
+	return e.toError()
+}
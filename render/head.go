@@ -0,0 +1,141 @@
+package render
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// HeadTag is an element that is added to the <head> of the document, such as a <title> or <meta>.
+// On client side navigation, the elements of the previous page are replaced with the ones of the new page.
+type HeadTag struct {
+	// Tag is the name of the element, such as "meta".
+	Tag string
+	// Attrs are the attributes of the element.
+	Attrs map[string]string
+	// Content is the text content of the element. It is escaped, except for <script> elements,
+	// where it is written as is and must not contain "</script".
+	Content string
+	// Key identifies the tag for deduplication. If several tags have the same non-empty key, only the last one is kept,
+	// in the position of the first one.
+	Key string
+}
+
+// headAttr marks the elements that were added from go, so they can be replaced on the client.
+const headAttr = "data-golte-head"
+
+// voidTags are the elements in the head that don't have a closing tag.
+var voidTags = map[string]bool{"meta": true, "link": true, "base": true}
+
+// dedupeHead removes tags that are replaced by later tags with the same key.
+func dedupeHead(tags []HeadTag) []HeadTag {
+	result := make([]HeadTag, 0, len(tags))
+	index := map[string]int{}
+	for _, tag := range tags {
+		if tag.Key == "" {
+			result = append(result, tag)
+			continue
+		}
+
+		if i, ok := index[tag.Key]; ok {
+			result[i] = tag
+			continue
+		}
+
+		index[tag.Key] = len(result)
+		result = append(result, tag)
+	}
+	return result
+}
+
+// nonceTags are the elements in the head that the Content-Security-Policy nonce applies to.
+var nonceTags = map[string]bool{"script": true, "style": true, "link": true}
+
+// nonceAttr returns the nonce attribute, or an empty string if there is no nonce.
+func nonceAttr(nonce string) string {
+	if nonce == "" {
+		return ""
+	}
+	return ` nonce="` + template.HTMLEscapeString(nonce) + `"`
+}
+
+// headHTML returns the html of the head tags, adding the nonce to the elements it applies to.
+func headHTML(tags []HeadTag, nonce string) string {
+	var b strings.Builder
+	for _, tag := range dedupeHead(tags) {
+		b.WriteString("\n<")
+		b.WriteString(tag.Tag)
+
+		names := make([]string, 0, len(tag.Attrs))
+		for name := range tag.Attrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			b.WriteString(" ")
+			b.WriteString(name)
+			b.WriteString(`="`)
+			b.WriteString(template.HTMLEscapeString(tag.Attrs[name]))
+			b.WriteString(`"`)
+		}
+		if nonceTags[tag.Tag] {
+			b.WriteString(nonceAttr(nonce))
+		}
+		b.WriteString(" " + headAttr + ">")
+
+		if voidTags[tag.Tag] {
+			continue
+		}
+
+		if tag.Tag == "script" {
+			b.WriteString(tag.Content)
+		} else {
+			b.WriteString(template.HTMLEscapeString(tag.Content))
+		}
+		b.WriteString("</" + tag.Tag + ">")
+	}
+	return b.String()
+}
+
+// titleRe and charsetRe match the elements that can only appear once in a document.
+var (
+	titleRe   = regexp.MustCompile(`(?is)<title[\s>].*?</title>`)
+	charsetRe = regexp.MustCompile(`(?i)<meta\s[^>]*\bcharset\s*=[^>]*>`)
+)
+
+// uniqueTags reports whether the tags contain a title and a charset.
+func uniqueTags(tags []HeadTag) (title, charset bool) {
+	for _, tag := range tags {
+		switch {
+		case tag.Tag == "title":
+			title = true
+		case tag.Tag == "meta" && tag.Attrs["charset"] != "":
+			charset = true
+		}
+	}
+	return title, charset
+}
+
+// stripHead removes the title and charset from the head of the components if they are set by the tags,
+// so that the ones from go take precedence.
+func stripHead(head string, tags []HeadTag) string {
+	title, charset := uniqueTags(tags)
+	if title {
+		head = titleRe.ReplaceAllString(head, "")
+	}
+	if charset {
+		head = charsetRe.ReplaceAllString(head, "")
+	}
+	return head
+}
+
+// head returns the html of the tags for the head of the document, which comes before the head of the components.
+// The charset of the template is included unless the tags contain one.
+func (r *Renderer) head(tags []HeadTag, nonce string) string {
+	if _, charset := uniqueTags(tags); charset || r.charset == "" {
+		return headHTML(tags, nonce)
+	}
+	return "\n" + r.charset + headHTML(tags, nonce)
+}
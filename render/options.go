@@ -0,0 +1,141 @@
+package render
+
+import (
+	"log/slog"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Option configures a [Renderer].
+type Option func(*options)
+
+type options struct {
+	poolSize  int
+	poolWait  time.Duration
+	timeout   time.Duration
+	streaming bool
+	external  bool
+	logger    *slog.Logger
+	basePath  string
+	origin    string
+	funcs     template.FuncMap
+}
+
+func defaultOptions() options {
+	return options{
+		poolSize: runtime.GOMAXPROCS(0),
+		logger:   slog.Default(),
+	}
+}
+
+// WithPoolSize sets the number of JS runtimes the renderer creates.
+// Each runtime can serve one render at a time, so this is the maximum number of concurrent renders.
+// It defaults to [runtime.GOMAXPROCS].
+func WithPoolSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.poolSize = n
+		}
+	}
+}
+
+// WithPoolWait sets the maximum time a render will wait for a JS runtime to become available.
+// If the wait time elapses, [ErrPoolTimeout] is returned. By default, renders will wait indefinitely.
+func WithPoolWait(d time.Duration) Option {
+	return func(o *options) {
+		o.poolWait = d
+	}
+}
+
+// WithTimeout sets the default maximum duration of a render.
+// If rendering takes longer, it is interrupted and a [*TimeoutError] is returned.
+// This applies in addition to any deadline of the context passed to [Renderer.Render].
+// By default, there is no timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithStreaming enables streaming responses for server side rendering.
+// When enabled, the part of the template before the body is sent and flushed before components are rendered,
+// so the browser can start loading stylesheets while rendering is still in progress.
+//
+// In streaming mode, the head of the template only contains the stylesheet links.
+// Elements from <svelte:head> are written at the start of the body instead,
+// and the status code can no longer be changed once rendering starts.
+// If rendering fails, the error page is rendered into the body, but the status code that was sent is kept.
+// Streaming is only used when the [http.ResponseWriter] implements [http.Flusher]
+// and the template contains the body exactly once.
+func WithStreaming(enabled bool) Option {
+	return func(o *options) {
+		o.streaming = enabled
+	}
+}
+
+// WithLogger sets the logger used for console output of components and for errors caught while rendering.
+// The console methods debug, log, info, warn, and error are logged at the matching levels,
+// along with the context passed to the render and [RenderData.LogAttrs].
+// It defaults to [slog.Default].
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithBasePath sets the path prefix that the app is served under, such as "/billing".
+// The URLs of assets, including those referenced in the template, are prefixed with it.
+func WithBasePath(path string) Option {
+	return func(o *options) {
+		o.basePath = CleanBasePath(path)
+	}
+}
+
+// CleanBasePath ensures that a base path starts with a slash and doesn't end with one.
+// The root path is represented by an empty string.
+func CleanBasePath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// WithFuncs adds functions that can be called from template.html.
+// The template is executed with html/template, so the results are escaped unless they are of a type such as [html/template.HTML].
+// Calling this multiple times merges the functions.
+func WithFuncs(funcs template.FuncMap) Option {
+	return func(o *options) {
+		if o.funcs == nil {
+			o.funcs = template.FuncMap{}
+		}
+		for name, fn := range funcs {
+			o.funcs[name] = fn
+		}
+	}
+}
+
+// WithExternalHydration writes the hydration data as a <script type="application/json"> block,
+// which is read by an external module instead of an inline script.
+// This allows using a Content-Security-Policy without 'unsafe-inline' or nonces for scripts.
+func WithExternalHydration(enabled bool) Option {
+	return func(o *options) {
+		o.external = enabled
+	}
+}
+
+// WithAssetOrigin serves the assets from another origin, such as a CDN, instead of the origin of the page.
+// Asset URLs in stylesheet links, the hydration script, the template, and client side navigation
+// are prefixed with the origin, for example "https://cdn.example.com/golte_/entries/app.js".
+// The base path is kept, so a CDN that pulls from the app's origin finds the files at the same path.
+//
+// Since modules and stylesheets with an integrity are loaded with CORS, the CDN must send Access-Control-Allow-Origin.
+func WithAssetOrigin(origin string) Option {
+	return func(o *options) {
+		o.origin = strings.TrimSuffix(origin, "/")
+	}
+}
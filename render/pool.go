@@ -0,0 +1,204 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/require"
+	"github.com/dop251/goja_nodejs/url"
+)
+
+// ErrPoolTimeout is returned when no JS runtime became available within the configured wait time.
+var ErrPoolTimeout = errors.New("golte: timed out waiting for a js runtime")
+
+// vm is a single JS runtime along with the exports of the server build.
+// A vm must only be used by one goroutine at a time.
+type vm struct {
+	runtime    *goja.Runtime
+	renderfile renderfile
+	infofile   Info
+
+	// logger, ctx, and attrs are used by the console; ctx and attrs belong to the render in progress
+	logger *slog.Logger
+	ctx    context.Context
+	attrs  []slog.Attr
+}
+
+// newVM initializes a runtime and loads the server build into it.
+// The registry caches compiled modules, so it can be shared between vms.
+// The urls of assets imported by components are prefixed with assetPrefix, which is the asset origin and base path.
+func newVM(registry *require.Registry, logger *slog.Logger, assetPrefix string) (*vm, error) {
+	v := &vm{
+		runtime: goja.New(),
+		logger:  logger,
+	}
+	runtime := v.runtime
+	runtime.SetFieldNameMapper(fieldMapper{"json"})
+
+	// read by the server build when it is loaded
+	if err := runtime.Set("golteAssetPrefix", assetPrefix); err != nil {
+		return nil, err
+	}
+
+	req := registry.Enable(runtime)
+	v.enableConsole()
+	url.Enable(runtime)
+
+	if err := export(runtime, req, "./render.js", &v.renderfile); err != nil {
+		return nil, err
+	}
+
+	if err := export(runtime, req, "./info.js", &v.infofile); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// render calls the render function of the server build.
+// If ctx is done before rendering finishes, the runtime is interrupted and a [*TimeoutError] is returned.
+// Exceptions thrown by the server build are returned as an [*Error].
+func (v *vm) render(ctx context.Context, data RenderData, hydrate hydrateOptions) (Document, error) {
+	v.ctx, v.attrs = ctx, data.LogAttrs
+	defer func() { v.ctx, v.attrs = nil, nil }()
+
+	if ctx.Done() == nil {
+		return v.call(data, hydrate)
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			v.runtime.Interrupt(ctx.Err())
+		case <-stop:
+		}
+	}()
+
+	doc, err := v.call(data, hydrate)
+	close(stop)
+	<-stopped
+
+	// the interrupt could have happened after rendering finished, so it has to be cleared before reuse
+	v.runtime.ClearInterrupt()
+
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return doc, &TimeoutError{Cause: ctx.Err()}
+	}
+
+	return doc, err
+}
+
+func (v *vm) call(data RenderData, hydrate hydrateOptions) (Document, error) {
+	res, err := v.renderfile.Render(data.Entries, data.SCData, data.ErrPage, hydrate)
+
+	var ex *goja.Exception
+	if errors.As(err, &ex) {
+		return Document{}, exceptionToError(ex)
+	}
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{
+		Head:     res.Head,
+		Body:     res.Body,
+		HasError: res.HasError,
+		Err:      res.Error.toError(),
+	}, nil
+}
+
+// export requires the module at path and exports it to target.
+func export(runtime *goja.Runtime, req *require.RequireModule, path string, target any) error {
+	module, err := req.Require(path)
+	if err != nil {
+		return err
+	}
+
+	return runtime.ExportTo(module, target)
+}
+
+// ReadInfo reads the info of the server build in fsys, without loading the components like [New] does.
+// The FS should be the "server" subdirectory of the build output from "npx golte".
+func ReadInfo(fsys fs.FS) (Info, error) {
+	registry := require.NewRegistryWithLoader(func(path string) ([]byte, error) {
+		return fs.ReadFile(fsys, path)
+	})
+
+	runtime := goja.New()
+	runtime.SetFieldNameMapper(fieldMapper{"json"})
+
+	var info Info
+	err := export(runtime, registry.Enable(runtime), "./info.js", &info)
+	return info, err
+}
+
+// pool is a fixed size pool of vms.
+type pool struct {
+	vms  chan *vm
+	wait time.Duration
+}
+
+func newPool(fsys fs.FS, size int, wait time.Duration, logger *slog.Logger, assetPrefix string) (*pool, error) {
+	registry := require.NewRegistryWithLoader(func(path string) ([]byte, error) {
+		return fs.ReadFile(fsys, path)
+	})
+
+	p := &pool{
+		vms:  make(chan *vm, size),
+		wait: wait,
+	}
+
+	for i := 0; i < size; i++ {
+		vm, err := newVM(registry, logger, assetPrefix)
+		if err != nil {
+			return nil, err
+		}
+		p.vms <- vm
+	}
+
+	return p, nil
+}
+
+// get takes a vm from the pool, blocking until one is available.
+// If the pool has a wait time and it elapses, ErrPoolTimeout is returned.
+// If ctx is done first, or already done, a *TimeoutError is returned.
+// The vm must be returned to the pool with put.
+func (p *pool) get(ctx context.Context) (*vm, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &TimeoutError{Cause: err}
+	}
+
+	select {
+	case vm := <-p.vms:
+		return vm, nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if p.wait > 0 {
+		timer := time.NewTimer(p.wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case vm := <-p.vms:
+		return vm, nil
+	case <-timeout:
+		return nil, ErrPoolTimeout
+	case <-ctx.Done():
+		return nil, &TimeoutError{Cause: ctx.Err()}
+	}
+}
+
+func (p *pool) put(vm *vm) {
+	p.vms <- vm
+}
@@ -1,110 +1,414 @@
 package render
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
 	"io/fs"
+	"log/slog"
 	"net/http"
-	"sync"
-	"text/template"
-
-	"github.com/dop251/goja"
-	"github.com/dop251/goja_nodejs/console"
-	"github.com/dop251/goja_nodejs/require"
-	"github.com/dop251/goja_nodejs/url"
+	"strings"
+	texttemplate "text/template"
+	"time"
 )
 
 // Renderer is a renderer for svelte components. It is safe to use concurrently across threads.
+// It keeps a pool of independent JS runtimes, so multiple renders can run in parallel.
 type Renderer struct {
 	renderfile renderfile
-	infofile   infofile
+	infofile   Info
 
-	template *template.Template
-	vm       *goja.Runtime
-	mtx      sync.Mutex
+	template  *template.Template
+	charset   string // the charset element of the template, which is written with the head
+	pool      *pool
+	timeout   time.Duration
+	streaming bool
+	external  bool
+	logger    *slog.Logger
+	basePath  string
+	origin    string
 }
 
-// New constructs a renderer from the given FS.
-// The FS should be the "server" subdirectory of the build output from "npx golte".
-// The second argument is the path where the JS, CSS, and other assets are expected to be served.
-func New(fsys fs.FS) *Renderer {
-	tmpl := template.Must(template.New("").ParseFS(fsys, "template.html")).Lookup("template.html")
+// parseTemplate parses the template of the build with the given functions.
+// It is an html/template, so values other than the head and body are escaped according to their context.
+// References to assets in the template are prefixed with prefix, which is the asset origin and base path.
+// If the template has a head, its charset element is removed and returned, so that it can be replaced from go.
+func parseTemplate(fsys fs.FS, assets, prefix string, funcs texttemplate.FuncMap) (tmpl *template.Template, charset string, err error) {
+	b, err := fs.ReadFile(fsys, "template.html")
+	if err != nil {
+		return nil, "", err
+	}
 
-	vm := goja.New()
-	vm.SetFieldNameMapper(fieldMapper{"json"})
+	text := string(b)
+	if prefix != "" {
+		for _, quote := range []string{`"`, `'`} {
+			text = strings.ReplaceAll(text, quote+"/"+assets+"/", quote+prefix+"/"+assets+"/")
+		}
+	}
 
-	require.NewRegistryWithLoader(func(path string) ([]byte, error) {
-		return fs.ReadFile(fsys, path)
-	}).Enable(vm)
+	if strings.Contains(text, ".Head") {
+		if loc := charsetRe.FindStringIndex(text); loc != nil {
+			charset = text[loc[0]:loc[1]]
+			text = text[:loc[0]] + text[loc[1]:]
+		}
+	}
 
-	console.Enable(vm)
-	url.Enable(vm)
+	tmpl, err = template.New("template.html").Funcs(template.FuncMap(funcs)).Parse(text)
+	return tmpl, charset, err
+}
 
-	var renderfile renderfile
-	err := vm.ExportTo(require.Require(vm, "./render.js"), &renderfile)
+// New constructs a renderer from the given FS.
+// The FS should be the "server" subdirectory of the build output from "npx golte".
+func New(fsys fs.FS, opts ...Option) *Renderer {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	pool, err := newPool(fsys, o.poolSize, o.poolWait, o.logger, o.origin+o.basePath)
 	if err != nil {
 		panic(err)
 	}
 
-	var infofile infofile
-	err = vm.ExportTo(require.Require(vm, "./info.js"), &infofile)
+	// the manifest and info are the same for every runtime
+	vm, _ := pool.get(context.Background())
+	defer pool.put(vm)
+
+	tmpl, charset, err := parseTemplate(fsys, vm.infofile.Assets, o.origin+o.basePath, o.funcs)
 	if err != nil {
 		panic(err)
 	}
 
 	return &Renderer{
 		template:   tmpl,
-		vm:         vm,
-		renderfile: renderfile,
-		infofile:   infofile,
+		charset:    charset,
+		pool:       pool,
+		basePath:   o.basePath,
+		origin:     o.origin,
+		timeout:    o.timeout,
+		streaming:  o.streaming,
+		external:   o.external,
+		logger:     o.logger,
+		renderfile: vm.renderfile,
+		infofile:   vm.infofile,
 	}
 }
 
+// DefaultErrPage is the name of the error component that is used when no other error page is set.
+const DefaultErrPage = "$$$GOLTE_DEFAULT_ERROR$$$"
+
 type RenderData struct {
 	Entries []Entry
 	ErrPage string
 	SCData  SvelteContextData
+
+	// Head contains elements that are added to the <head> of the document.
+	Head []HeadTag
+
+	// TemplateData is available to template.html as .Data, for example {{ .Data.lang }}.
+	// The template is executed with html/template, so the values are escaped according to their context.
+	// Use [html/template.HTML] and the related types for values that are trusted.
+	TemplateData map[string]any
+
+	// Nonce is the Content-Security-Policy nonce of the response. If it is not empty, it is added to the hydration script,
+	// the stylesheet links, and the script, style and link elements of [RenderData.Head].
+	// It is also available to template.html as .Nonce.
+	Nonce string
+
+	// NoHydrate renders static html that is not hydrated on the client, such as the body of an email or a PDF.
+	// The hydration script and the modulepreload links are left out; stylesheet links are still added.
+	NoHydrate bool
+
+	// LogAttrs are added to everything that is logged during the render, such as console output.
+	// They can be used to correlate log lines with the request being rendered.
+	LogAttrs []slog.Attr
+}
+
+// Document is the result of rendering, before it is inserted into the template.
+type Document struct {
+	// Head contains the elements for the <head> of the document: [RenderData.Head] and the charset of the template,
+	// followed by the head of the components and the stylesheet and modulepreload links.
+	// A title or charset in [RenderData.Head] replaces the one of the components and the template.
+	Head string
+	// Body contains the rendered components along with the hydration script, unless [RenderData.NoHydrate] is set.
+	Body string
+	// HasError is true if an error occurred while rendering a component and the error page was rendered in its place.
+	HasError bool
+	// Err is the error that caused the error page to be rendered, if HasError is true.
+	Err *Error
+	// Data is [RenderData.TemplateData].
+	Data map[string]any
+	// Nonce is [RenderData.Nonce].
+	Nonce string
+}
+
+// templateDocument is the value that the template is executed with.
+// The head and body are trusted html, while the other values are escaped by html/template.
+type templateDocument struct {
+	Head     template.HTML
+	Body     template.HTML
+	HasError bool
+	Err      *Error
+	Data     map[string]any
+	Nonce    string
+}
+
+// execute executes the template with the document.
+func (r *Renderer) execute(w io.Writer, doc Document) error {
+	return r.template.Execute(w, templateDocument{
+		Head:     template.HTML(doc.Head),
+		Body:     template.HTML(doc.Body),
+		HasError: doc.HasError,
+		Err:      doc.Err,
+		Data:     doc.Data,
+		Nonce:    doc.Nonce,
+	})
+}
+
+// RenderHTML renders a slice of entries without executing the template.
+// Rendering is bound to ctx; if ctx is done before rendering finishes, a [*TimeoutError] is returned.
+func (r *Renderer) RenderHTML(ctx context.Context, data RenderData) (Document, error) {
+	links, err := r.links(data)
+	if err != nil {
+		return Document{}, err
+	}
+
+	doc, err := r.render(ctx, data)
+	if err != nil {
+		return Document{}, err
+	}
+
+	// the tags from go come first, so that their title is the one used by the browser
+	doc.Head = r.head(data.Head, data.Nonce) + stripHead(doc.Head, data.Head) + links
+	doc.Data = data.TemplateData
+	doc.Nonce = data.Nonce
+	return doc, nil
 }
 
-// Render renders a slice of entries into the writer.
-func (r *Renderer) Render(w http.ResponseWriter, data RenderData, csr bool) error {
+// RenderTo renders a slice of entries and executes the template into the writer.
+// If streaming is enabled and the writer implements [http.Flusher], the document is streamed.
+func (r *Renderer) RenderTo(ctx context.Context, w io.Writer, data RenderData) error {
+	if ok, err := r.tryStream(ctx, w, data, nil); ok {
+		return err
+	}
+
+	doc, err := r.RenderHTML(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	return r.execute(w, doc)
+}
+
+// Render renders a slice of entries into the response.
+// If csr is true, the entries are written as JSON to be rendered by the client instead.
+// It is built on top of [Renderer.RenderTo], additionally setting the headers and status code.
+//
+// The status code is taken from the page data, or 200 if it is not set.
+// If an error page was rendered because a component threw an error, the status code is 500 instead,
+// which is also the status in the page data that is passed to the client.
+// In streaming mode, the status code was already sent by then, so only the page data is changed.
+func (r *Renderer) Render(ctx context.Context, w http.ResponseWriter, data RenderData, csr bool) error {
+	status := data.SCData.Page.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
 	if !csr {
-		r.mtx.Lock()
-		result, err := r.renderfile.Render(data.Entries, data.SCData, data.ErrPage)
-		r.mtx.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Vary", "Golte")
+
+		if ok, err := r.tryStream(ctx, w, data, func() { w.WriteHeader(status) }); ok {
+			return err
+		}
 
+		doc, err := r.RenderHTML(ctx, data)
 		if err != nil {
 			return err
 		}
 
-		if result.HasError {
-			w.WriteHeader(http.StatusInternalServerError)
+		if doc.HasError {
+			status = http.StatusInternalServerError
 		}
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Header().Set("Vary", "Golte")
+		// the template is executed before the status is written, so that its errors can still be reported
+		var b bytes.Buffer
+		if err := r.execute(&b, doc); err != nil {
+			return err
+		}
 
-		return r.template.Execute(w, result)
+		w.WriteHeader(status)
+		_, err = b.WriteTo(w)
+		return err
 	}
 
 	var resp csrResponse
+	resp.Integrity = map[string]string{}
+	errPage := r.renderfile.Manifest[data.ErrPage]
 	for _, v := range data.Entries {
 		comp := r.renderfile.Manifest[v.Comp]
-		resp.Entries = append(resp.Entries, responseEntry{
-			File:  comp.Client,
-			Props: v.Props,
-			CSS:   comp.CSS,
-		})
+		entry := responseEntry{
+			File:    r.assetURL(comp.Client),
+			Props:   v.Props,
+			CSS:     append(r.assetURLs(comp.CSS), r.assetURLs(errPage.CSS)...),
+			Modules: append(r.assetURLs(comp.Modules), r.assetURLs(errPage.Modules)...),
+			ErrPage: r.assetURL(errPage.Client),
+		}
+		resp.Entries = append(resp.Entries, entry)
+
+		for _, paths := range [][]string{comp.CSS, comp.Modules, errPage.CSS, errPage.Modules} {
+			for _, path := range paths {
+				if digest := r.renderfile.Integrity[path]; digest != "" {
+					resp.Integrity[r.assetURL(path)] = digest
+				}
+			}
+		}
+
+		if v.ErrPage != "" {
+			errPage = r.renderfile.Manifest[v.ErrPage]
+		}
 	}
 
-	resp.ErrPage = responseEntry{
-		File: r.renderfile.Manifest[data.ErrPage].Client,
-		CSS:  r.renderfile.Manifest[data.ErrPage].CSS,
+	resp.Head = dedupeHead(data.Head)
+	resp.ContextData = data.SCData
+	resp.ContextData.BasePath = r.basePath
+	resp.ContextData.Page.Status = status
+	resp.Status = status
+
+	// props that can't be encoded are reported before the status is written
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("Vary", "Golte")
+	w.WriteHeader(status)
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// render takes a vm from the pool and renders the data with it.
+func (r *Renderer) render(ctx context.Context, data RenderData) (Document, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	vm, err := r.pool.get(ctx)
+	if err != nil {
+		return Document{}, err
+	}
+	defer r.pool.put(vm)
+
+	data.SCData.BasePath = r.basePath
+
+	doc, err := vm.render(ctx, data, hydrateOptions{Nonce: data.Nonce, External: r.external, AssetPrefix: r.origin + r.basePath, NoHydrate: data.NoHydrate})
+	if doc.Err != nil {
+		attrs := append([]slog.Attr{
+			slog.String("component", doc.Err.Component),
+			slog.String("error", doc.Err.Message),
+			slog.String("stack", doc.Err.StackTrace()),
+		}, data.LogAttrs...)
+		r.logger.LogAttrs(ctx, slog.LevelError, "error while rendering component", attrs...)
+	}
+
+	return doc, err
+}
+
+// links returns the stylesheet links for the css of every component in data,
+// and modulepreload links for the js files that have an integrity, so that they are verified when they are imported.
+// It returns an error if any of the components do not exist.
+func (r *Renderer) links(data RenderData) (string, error) {
+	comps := make([]string, 0, len(data.Entries)+1)
+	for _, e := range data.Entries {
+		comps = append(comps, e.Comp)
+		if e.ErrPage != "" {
+			comps = append(comps, e.ErrPage)
+		}
+	}
+	comps = append(comps, data.ErrPage)
+
+	var css, modules []string
+	for _, name := range comps {
+		comp, ok := r.renderfile.Manifest[name]
+		if !ok {
+			return "", fmt.Errorf("%q is not a component", name)
+		}
+		css = append(css, comp.CSS...)
+		modules = append(modules, comp.Modules...)
+	}
+
+	if data.NoHydrate {
+		// nothing is imported on the client
+		modules = nil
+	} else if r.external {
+		modules = append(modules, r.renderfile.BootModules...)
+	} else {
+		modules = append(modules, r.renderfile.HydrateModules...)
+	}
 
-	return json.NewEncoder(w).Encode(resp)
+	var links strings.Builder
+	seen := map[string]bool{}
+	for _, path := range css {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		fmt.Fprintf(&links, "\n<link href=\"%s\" rel=\"stylesheet\"%s%s>", template.HTMLEscapeString(r.assetURL(path)), r.integrityAttrs(path), nonceAttr(data.Nonce))
+	}
+
+	for _, path := range modules {
+		if seen[path] || r.renderfile.Integrity[path] == "" {
+			continue
+		}
+		seen[path] = true
+		fmt.Fprintf(&links, "\n<link href=\"%s\" rel=\"modulepreload\"%s%s>", template.HTMLEscapeString(r.assetURL(path)), r.integrityAttrs(path), nonceAttr(data.Nonce))
+	}
+
+	return links.String(), nil
+}
+
+// integrityAttrs returns the integrity and crossorigin attributes of an asset, or an empty string if its integrity is unknown.
+func (r *Renderer) integrityAttrs(path string) string {
+	digest := r.renderfile.Integrity[path]
+	if digest == "" {
+		return ""
+	}
+	return ` integrity="` + template.HTMLEscapeString(digest) + `" crossorigin="anonymous"`
+}
+
+// assetURL returns the URL of an asset from the manifest, which is relative to the root.
+func (r *Renderer) assetURL(path string) string {
+	return r.origin + r.basePath + path
+}
+
+func (r *Renderer) assetURLs(paths []string) []string {
+	urls := make([]string, len(paths))
+	for i, path := range paths {
+		urls[i] = r.assetURL(path)
+	}
+	return urls
+}
+
+// BasePath returns the path prefix that the app is served under, or an empty string if it is served on the root.
+func (r *Renderer) BasePath() string {
+	return r.basePath
+}
+
+// AssetOrigin returns the origin that assets are served from, or an empty string if they are served from the origin of the page.
+func (r *Renderer) AssetOrigin() string {
+	return r.origin
+}
+
+// Dev reports whether the build was made in development mode.
+func (r *Renderer) Dev() bool {
+	return r.infofile.Dev
 }
 
 // Assets returns the "assets" field that was used in the golte configuration file.
@@ -112,41 +416,96 @@ func (r *Renderer) Assets() string {
 	return r.infofile.Assets
 }
 
-type result struct {
-	Head     string
-	Body     string
-	HasError bool
-}
-
 type renderfile struct {
 	Manifest map[string]struct {
 		Client string
 		CSS    []string
+		// Modules are the js files that the component loads, including its own.
+		Modules []string
 	}
-	Render func([]Entry, SvelteContextData, string) (result, error)
+	// Integrity is the subresource integrity of every client file, keyed by its path in the manifest.
+	Integrity map[string]string
+	// HydrateModules and BootModules are the js files loaded by the inline hydration script and the external boot module.
+	HydrateModules []string
+	BootModules    []string
+
+	Render func([]Entry, SvelteContextData, string, hydrateOptions) (renderResult, error)
+}
+
+// hydrateOptions configures the hydration script that is written by render.js.
+type hydrateOptions struct {
+	Nonce    string
+	External bool
+	// AssetPrefix is prepended to the asset paths of the manifest.
+	AssetPrefix string
+	// NoHydrate leaves out the hydration script.
+	NoHydrate bool
+}
+
+type renderResult struct {
+	Head     string
+	Body     string
+	HasError bool
+	Error    *jsError
 }
 
 // Entry represents a component to be rendered, along with its props.
 type Entry struct {
 	Comp  string
 	Props map[string]any
+	// ErrPage is the error component that is rendered in place of any component below this one that throws an error.
+	// If it is empty, the error component of the nearest entry above is used, or [RenderData.ErrPage] if there is none.
+	ErrPage string
 }
 
+// SvelteContextData is the data that is available to every component, both during SSR and on the client.
 type SvelteContextData struct {
-	URL string
+	URL  string
+	Page PageData
+	// BasePath is the path prefix that the app is served under. It is set by the renderer.
+	BasePath string
+	// Context contains values that are set as svelte contexts in the root component.
+	// Each value is wrapped in a store, which is updated on client side navigation.
+	Context map[string]any
+}
+
+// PageData is the value of the page store.
+type PageData struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// Route is the pattern of the route that matched the request, such as "/user/{id}".
+	Route string
+	// Params are the parameters of the route, such as {"id": "123"}.
+	Params map[string]string
+	// Headers are the request headers that are exposed to components.
+	Headers map[string]string
+	// Cookies are the request cookies that are exposed to components.
+	Cookies map[string]string
 }
 
 type csrResponse struct {
-	Entries []responseEntry
-	ErrPage responseEntry
+	Status      int
+	Entries     []responseEntry
+	Head        []HeadTag
+	ContextData SvelteContextData
+	// Integrity is the subresource integrity of the css and js files of the entries, keyed by url.
+	Integrity map[string]string
 }
 
 type responseEntry struct {
 	File  string
 	Props map[string]any
-	CSS   []string
+	// CSS and Modules also contain the files of the error component
+	CSS     []string
+	Modules []string
+	ErrPage string
 }
 
-type infofile struct {
+// Info describes a server build.
+type Info struct {
+	// Assets is the "assets" field that was used in the golte configuration file.
+	// Client files are served under it.
 	Assets string
+	// Dev is true if the build was made in development mode.
+	Dev bool
 }
@@ -0,0 +1,618 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"text/template"
+	"time"
+)
+
+// testFS is a minimal server build. Rendering the "loop" component never finishes.
+var testFS = fstest.MapFS{
+	"template.html": {Data: []byte(`<head>{{ .Head }}</head><body>{{ .Body }}</body>`)},
+	"info.js":       {Data: []byte(`exports.Assets = "golte_";`)},
+	"render.js": {Data: []byte(`
+		exports.Manifest = {
+			"page": { Client: "/golte_/page.js", CSS: ["/golte_/page.css"] },
+			"loop": { Client: "/golte_/loop.js", CSS: [] },
+			"error": { Client: "/golte_/error.js", CSS: [] },
+			"throw": { Client: "/golte_/throw.js", CSS: [] },
+			"log": { Client: "/golte_/log.js", CSS: [] },
+		};
+		exports.Render = function (entries, contextData, errPage) {
+			if (entries[0].Comp === "loop") while (true) {}
+			if (entries[0].Comp === "log") console.warn("hello %s", "world");
+			if (entries[0].Comp === "throw") {
+				const err = new Error("oops");
+				err.component = "throw";
+				throw err;
+			}
+			return { Head: "<title>t</title>", Body: "<p>" + entries[0].Comp + "</p>", HasError: false };
+		};
+	`)},
+}
+
+// withFiles returns a copy of testFS with the given files added or replaced.
+func withFiles(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for k, v := range testFS {
+		fsys[k] = v
+	}
+	for k, v := range files {
+		fsys[k] = &fstest.MapFile{Data: []byte(v)}
+	}
+	return fsys
+}
+
+func renderData(comp string) RenderData {
+	return RenderData{Entries: []Entry{{Comp: comp}}, ErrPage: "error"}
+}
+
+func TestRenderTimeout(t *testing.T) {
+	r := New(testFS, WithPoolSize(1), WithTimeout(50*time.Millisecond))
+
+	err := r.Render(context.Background(), httptest.NewRecorder(), renderData("loop"), false)
+	var terr *TimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatalf("expected *TimeoutError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected cause to be context.DeadlineExceeded, got %v", terr.Cause)
+	}
+
+	// the only runtime in the pool must be usable after being interrupted
+	rec := httptest.NewRecorder()
+	err = r.Render(context.Background(), rec, renderData("page"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := rec.Body.String(); body != `<head><title>t</title>`+"\n"+`<link href="/golte_/page.css" rel="stylesheet"></head><body><p>page</p></body>` {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestRenderCanceled(t *testing.T) {
+	r := New(testFS, WithPoolSize(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := r.Render(ctx, httptest.NewRecorder(), renderData("loop"), false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// a render with a context that is already canceled must not run at all
+	_, err = r.RenderHTML(ctx, renderData("page"))
+	var terr *TimeoutError
+	if !errors.As(err, &terr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected *TimeoutError caused by context.Canceled, got %v", err)
+	}
+}
+
+func TestRenderConcurrent(t *testing.T) {
+	r := New(testFS, WithPoolSize(4))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				doc, err := r.RenderHTML(context.Background(), renderData("page"))
+				if err != nil {
+					errs <- err
+					return
+				}
+				if doc.Body != "<p>page</p>" {
+					errs <- fmt.Errorf("unexpected body %q", doc.Body)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPoolWait(t *testing.T) {
+	r := New(testFS, WithPoolSize(1), WithPoolWait(20*time.Millisecond))
+
+	// hold the only runtime so that the render has to wait for it
+	vm, err := r.pool.get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.RenderHTML(context.Background(), renderData("page"))
+	if !errors.Is(err, ErrPoolTimeout) {
+		t.Fatalf("expected ErrPoolTimeout, got %v", err)
+	}
+
+	r.pool.put(vm)
+	_, err = r.RenderHTML(context.Background(), renderData("page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderStreaming(t *testing.T) {
+	r := New(testFS, WithPoolSize(1), WithTimeout(50*time.Millisecond), WithStreaming(true))
+
+	rec := httptest.NewRecorder()
+	err := r.Render(context.Background(), rec, renderData("page"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Flushed {
+		t.Error("head was not flushed")
+	}
+	if body := rec.Body.String(); body != `<head>`+"\n"+`<link href="/golte_/page.css" rel="stylesheet"></head><body><title>t</title><p>page</p></body>` {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	err = r.Render(context.Background(), rec, renderData("loop"), false)
+	var serr *StreamError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected *StreamError, got %v", err)
+	}
+	if body := rec.Body.String(); body != "<head></head><body><title>t</title><p>error</p></body>" {
+		t.Errorf("error page was not rendered into the document after failure: %q", body)
+	}
+
+	// the error page fails as well
+	data := renderData("loop")
+	data.ErrPage = "loop"
+	rec = httptest.NewRecorder()
+	err = r.Render(context.Background(), rec, data, false)
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected *StreamError, got %v", err)
+	}
+	if body := rec.Body.String(); body != "<head></head><body>"+staticErrorHTML+"</body>" {
+		t.Errorf("static error was not written after failure: %q", body)
+	}
+}
+
+func TestRenderStatus(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		r := New(testFS, WithPoolSize(1), WithStreaming(streaming))
+		for _, csr := range []bool{false, true} {
+			data := renderData("page")
+			data.SCData.Page.Status = 404
+
+			rec := httptest.NewRecorder()
+			err := r.Render(context.Background(), rec, data, csr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != 404 {
+				t.Errorf("streaming=%v csr=%v: expected status 404, got %d", streaming, csr, rec.Code)
+			}
+		}
+	}
+}
+
+func TestRenderPageData(t *testing.T) {
+	r := New(testFS, WithPoolSize(1))
+
+	data := renderData("page")
+	data.SCData.Page = PageData{
+		Route:   "/user/{id}",
+		Params:  map[string]string{"id": "123"},
+		Headers: map[string]string{"Accept-Language": "en"},
+		Cookies: map[string]string{"theme": "dark"},
+	}
+
+	rec := httptest.NewRecorder()
+	err := r.Render(context.Background(), rec, data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp csrResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := data.SCData.Page
+	expected.Status = 200
+	if !reflect.DeepEqual(resp.ContextData.Page, expected) {
+		t.Errorf("expected %+v, got %+v", expected, resp.ContextData.Page)
+	}
+}
+
+func TestRenderEncodingError(t *testing.T) {
+	fsys := withFiles(map[string]string{"template.html": `<body>{{ fail }}{{ .Body }}</body>`})
+	r := New(fsys, WithPoolSize(1), WithFuncs(template.FuncMap{"fail": func() (string, error) { return "", errors.New("fail") }}))
+
+	for _, csr := range []bool{false, true} {
+		data := renderData("page")
+		data.Entries[0].Props = map[string]any{"ch": make(chan int)}
+		data.SCData.Page.Status = 404
+
+		rec := httptest.NewRecorder()
+		err := r.Render(context.Background(), rec, data, csr)
+		if err == nil {
+			t.Fatalf("csr=%v: expected error", csr)
+		}
+		if rec.Code != 200 || rec.Body.Len() != 0 {
+			t.Errorf("csr=%v: expected no status or body to be written, got %d %q", csr, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestRenderErrorBoundary(t *testing.T) {
+	r := New(testFS, WithPoolSize(1))
+
+	data := RenderData{Entries: []Entry{{Comp: "page", ErrPage: "log"}, {Comp: "loop"}}, ErrPage: "error"}
+	rec := httptest.NewRecorder()
+	err := r.Render(context.Background(), rec, data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp csrResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 2 || resp.Entries[0].ErrPage != "/golte_/error.js" || resp.Entries[1].ErrPage != "/golte_/log.js" {
+		t.Errorf("unexpected entries %+v", resp.Entries)
+	}
+}
+
+func TestRenderHead(t *testing.T) {
+	r := New(testFS, WithPoolSize(1))
+
+	data := renderData("error")
+	data.Head = []HeadTag{
+		{Tag: "title", Content: "first", Key: "title"},
+		{Tag: "meta", Attrs: map[string]string{"name": "description", "content": `"quoted"`}},
+		{Tag: "script", Attrs: map[string]string{"type": "application/ld+json"}, Content: `{"a":1}`},
+		{Tag: "title", Content: "<second>", Key: "title"},
+	}
+
+	doc, err := r.RenderHTML(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the title from go replaces the one of the components
+	expected := "\n<title data-golte-head>&lt;second&gt;</title>" +
+		"\n<meta content=\"&#34;quoted&#34;\" name=\"description\" data-golte-head>" +
+		"\n<script type=\"application/ld+json\" data-golte-head>{\"a\":1}</script>"
+	if doc.Head != expected {
+		t.Errorf("unexpected head %q", doc.Head)
+	}
+}
+
+func TestRenderHeadCharset(t *testing.T) {
+	fsys := withFiles(map[string]string{"template.html": `<head><meta charset="utf-8" />{{ .Head }}</head><body>{{ .Body }}</body>`})
+
+	for _, streaming := range []bool{false, true} {
+		r := New(fsys, WithPoolSize(1), WithStreaming(streaming))
+
+		rec := httptest.NewRecorder()
+		err := r.Render(context.Background(), rec, renderData("error"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := rec.Body.String(); strings.Count(body, "charset") != 1 || !strings.HasPrefix(body, `<head>`+"\n"+`<meta charset="utf-8" />`) {
+			t.Errorf("streaming=%v: expected the charset of the template, got %q", streaming, body)
+		}
+
+		data := renderData("error")
+		data.Head = []HeadTag{{Tag: "meta", Attrs: map[string]string{"charset": "iso-8859-1"}, Key: "meta charset"}}
+		rec = httptest.NewRecorder()
+		err = r.Render(context.Background(), rec, data, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := rec.Body.String(); strings.Count(body, "charset") != 1 || !strings.Contains(body, `<meta charset="iso-8859-1" data-golte-head>`) {
+			t.Errorf("streaming=%v: expected only the charset from go, got %q", streaming, body)
+		}
+	}
+}
+
+func TestRenderNonce(t *testing.T) {
+	r := New(testFS, WithPoolSize(1))
+
+	data := renderData("page")
+	data.Nonce = "abc"
+	data.Head = []HeadTag{
+		{Tag: "meta", Attrs: map[string]string{"name": "description", "content": "d"}},
+		{Tag: "script", Attrs: map[string]string{"type": "application/ld+json"}, Content: "{}"},
+	}
+
+	doc, err := r.RenderHTML(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`<meta content="d" name="description" data-golte-head>`,
+		`<script type="application/ld+json" nonce="abc" data-golte-head>{}</script>`,
+		`<link href="/golte_/page.css" rel="stylesheet" nonce="abc">`,
+	} {
+		if !strings.Contains(doc.Head, s) {
+			t.Errorf("expected head to contain %s, got %q", s, doc.Head)
+		}
+	}
+	if doc.Nonce != "abc" {
+		t.Errorf("unexpected nonce %q", doc.Nonce)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	r := New(testFS, WithPoolSize(1))
+
+	doc, err := r.RenderHTML(context.Background(), renderData("page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Body != "<p>page</p>" || doc.HasError {
+		t.Errorf("unexpected document %+v", doc)
+	}
+
+	var b strings.Builder
+	err = r.RenderTo(context.Background(), &b, renderData("page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(b.String(), "<body><p>page</p></body>") {
+		t.Errorf("unexpected output %q", b.String())
+	}
+
+	_, err = r.RenderHTML(context.Background(), renderData("missing"))
+	if err == nil {
+		t.Error("expected error for missing component")
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	r := New(testFS, WithPoolSize(1))
+
+	_, err := r.RenderHTML(context.Background(), renderData("throw"))
+	var rerr *Error
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if rerr.Component != "throw" || rerr.Message != "oops" {
+		t.Errorf("unexpected error %+v", rerr)
+	}
+	if len(rerr.Stack) == 0 || rerr.Stack[0].File != "render.js" || rerr.Stack[0].Line == 0 {
+		t.Errorf("unexpected stack %+v", rerr.Stack)
+	}
+}
+
+func TestParseStack(t *testing.T) {
+	stack := "Error: oops\n\tat render (web/page/home.svelte:3:5(12))\n\tat web/layout/main.svelte:10:1(4)\n\tat native\n"
+	frames := parseStack(stack)
+	expected := []Frame{
+		{Function: "render", File: "web/page/home.svelte", Line: 3, Column: 5},
+		{File: "web/layout/main.svelte", Line: 10, Column: 1},
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("expected %d frames, got %+v", len(expected), frames)
+	}
+	for i := range expected {
+		if frames[i] != expected[i] {
+			t.Errorf("frame %d: expected %+v, got %+v", i, expected[i], frames[i])
+		}
+	}
+}
+
+func TestRenderConsole(t *testing.T) {
+	var b strings.Builder
+	logger := slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	r := New(testFS, WithPoolSize(1), WithLogger(logger))
+
+	data := renderData("log")
+	data.LogAttrs = []slog.Attr{slog.String("url", "http://localhost/log")}
+	_, err := r.RenderHTML(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "level=WARN msg=\"hello world\" url=http://localhost/log\n"
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	fsys := withFiles(map[string]string{"template.html": `<script src="/golte_/app.js"></script>{{ .Head }}`})
+
+	r := New(fsys, WithPoolSize(1), WithBasePath("billing/"))
+	if r.BasePath() != "/billing" {
+		t.Errorf("unexpected base path %q", r.BasePath())
+	}
+
+	var b strings.Builder
+	err := r.RenderTo(context.Background(), &b, renderData("page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{`<script src="/billing/golte_/app.js">`, `<link href="/billing/golte_/page.css"`} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("expected output to contain %s, got %q", s, b.String())
+		}
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	fsys := withFiles(map[string]string{"template.html": `<html lang="{{ .Data.lang }}"><head></head><body class="{{ upper .Data.theme }}">{{ .Body }}</body></html>`})
+
+	for _, streaming := range []bool{false, true} {
+		r := New(fsys, WithPoolSize(1), WithStreaming(streaming), WithFuncs(template.FuncMap{"upper": strings.ToUpper}))
+
+		data := renderData("page")
+		data.TemplateData = map[string]any{"lang": "en", "theme": "dark"}
+
+		rec := httptest.NewRecorder()
+		err := r.Render(context.Background(), rec, data, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range []string{`<html lang="en">`, `<body class="DARK">`, `<p>page</p>`} {
+			if !strings.Contains(rec.Body.String(), s) {
+				t.Errorf("streaming=%v: expected output to contain %s, got %q", streaming, s, rec.Body.String())
+			}
+		}
+
+		// values from the request must not be able to break out of the attribute
+		data.TemplateData = map[string]any{"lang": `"><script>alert(1)</script>`, "theme": "dark"}
+		rec = httptest.NewRecorder()
+		err = r.Render(context.Background(), rec, data, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := rec.Body.String(); strings.Contains(body, "<script>") || !strings.Contains(body, `<html lang="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">`) {
+			t.Errorf("streaming=%v: template data was not escaped: %q", streaming, body)
+		}
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	fsys := withFiles(map[string]string{"render.js": string(testFS["render.js"].Data) + `
+		exports.Manifest.page.Modules = ["/golte_/page.js", "/golte_/chunk.js"];
+		exports.HydrateModules = ["/golte_/hydrate.js"];
+		exports.Integrity = { "/golte_/page.css": "sha384-css", "/golte_/page.js": "sha384-page", "/golte_/hydrate.js": "sha384-hydrate" };
+	`})
+
+	r := New(fsys, WithPoolSize(1))
+
+	doc, err := r.RenderHTML(context.Background(), renderData("page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<title>t</title>" +
+		"\n<link href=\"/golte_/page.css\" rel=\"stylesheet\" integrity=\"sha384-css\" crossorigin=\"anonymous\">" +
+		"\n<link href=\"/golte_/page.js\" rel=\"modulepreload\" integrity=\"sha384-page\" crossorigin=\"anonymous\">" +
+		"\n<link href=\"/golte_/hydrate.js\" rel=\"modulepreload\" integrity=\"sha384-hydrate\" crossorigin=\"anonymous\">"
+	if doc.Head != expected {
+		t.Errorf("unexpected head %q", doc.Head)
+	}
+
+	rec := httptest.NewRecorder()
+	err = r.Render(context.Background(), rec, renderData("page"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp csrResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Integrity) != 2 || resp.Integrity["/golte_/page.css"] != "sha384-css" || resp.Integrity["/golte_/page.js"] != "sha384-page" {
+		t.Errorf("unexpected integrity %v", resp.Integrity)
+	}
+}
+
+func TestNoHydrate(t *testing.T) {
+	fsys := withFiles(map[string]string{"render.js": string(testFS["render.js"].Data) + `
+		exports.Manifest.page.Modules = ["/golte_/page.js"];
+		exports.Integrity = { "/golte_/page.js": "sha384-page" };
+		const render = exports.Render;
+		exports.Render = function (entries, contextData, errPage, opts) {
+			const doc = render(entries, contextData, errPage, opts);
+			if (!opts.NoHydrate) doc.Body += "<script></script>";
+			return doc;
+		};
+	`})
+
+	r := New(fsys, WithPoolSize(1))
+
+	data := renderData("page")
+	data.NoHydrate = true
+	doc, err := r.RenderHTML(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Body != "<p>page</p>" {
+		t.Errorf("unexpected body %q", doc.Body)
+	}
+	if doc.Head != "<title>t</title>\n<link href=\"/golte_/page.css\" rel=\"stylesheet\">" {
+		t.Errorf("unexpected head %q", doc.Head)
+	}
+
+	doc, err = r.RenderHTML(context.Background(), renderData("page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc.Body, "<script>") || !strings.Contains(doc.Head, "modulepreload") {
+		t.Errorf("expected hydration without NoHydrate, got %+v", doc)
+	}
+}
+
+func TestAssetOrigin(t *testing.T) {
+	// like a component that imports an asset, the url is computed when the server build is loaded
+	fsys := withFiles(map[string]string{
+		"template.html": `<script src="/golte_/app.js"></script>{{ .Head }}{{ .Body }}`,
+		"render.js": string(testFS["render.js"].Data) + `
+			const logo = golteAssetPrefix + "/golte_/assets/logo.png";
+			const render = exports.Render;
+			exports.Render = function (...args) {
+				const doc = render(...args);
+				doc.Body += '<img src="' + logo + '">';
+				return doc;
+			};
+		`,
+	})
+
+	r := New(fsys, WithPoolSize(1), WithBasePath("/billing"), WithAssetOrigin("https://cdn.example.com/"))
+
+	var b strings.Builder
+	err := r.RenderTo(context.Background(), &b, renderData("page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`<script src="https://cdn.example.com/billing/golte_/app.js">`,
+		`<link href="https://cdn.example.com/billing/golte_/page.css"`,
+		`<img src="https://cdn.example.com/billing/golte_/assets/logo.png">`,
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("expected output to contain %s, got %q", s, b.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	err = r.Render(context.Background(), rec, renderData("page"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp csrResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Entries[0].File != "https://cdn.example.com/billing/golte_/page.js" || resp.ContextData.BasePath != "/billing" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
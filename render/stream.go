@@ -0,0 +1,103 @@
+package render
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// bodyMarker is used in place of the body to find where the template has to be split for streaming.
+const bodyMarker = "\x00golte-body\x00"
+
+// tryStream streams the document into w if streaming is enabled, w implements [http.Flusher],
+// and the template can be split around the body. If ok is false, nothing was written.
+// If start is not nil, it is called right before the first write.
+func (r *Renderer) tryStream(ctx context.Context, w io.Writer, data RenderData, start func()) (ok bool, err error) {
+	f, ok := w.(http.Flusher)
+	if !ok || !r.streaming {
+		return false, nil
+	}
+
+	links, err := r.links(data)
+	if err != nil {
+		return true, err
+	}
+
+	before, after, ok := r.split(Document{Head: r.head(data.Head, data.Nonce) + links, Data: data.TemplateData, Nonce: data.Nonce})
+	if !ok {
+		return false, nil
+	}
+
+	if start != nil {
+		start()
+	}
+	return true, r.stream(ctx, w, f, data, before, after)
+}
+
+// split executes the template with the given document in place of the rendered one and splits the output around the body.
+// ok is false if the template can't be split, in which case the response should not be streamed.
+func (r *Renderer) split(doc Document) (before, after string, ok bool) {
+	doc.Body = bodyMarker
+	var b strings.Builder
+	err := r.execute(&b, doc)
+	if err != nil {
+		return "", "", false
+	}
+
+	before, after, ok = strings.Cut(b.String(), bodyMarker)
+	if !ok || strings.Contains(after, bodyMarker) {
+		return "", "", false
+	}
+
+	return before, after, true
+}
+
+// stream writes and flushes the template up to the body before rendering,
+// then writes the rendered components followed by the rest of the template.
+// If rendering fails, the error page is rendered into the body, the document is closed,
+// and the error is returned as a [*StreamError].
+func (r *Renderer) stream(ctx context.Context, w io.Writer, f http.Flusher, data RenderData, before, after string) error {
+	_, err := io.WriteString(w, before)
+	if err != nil {
+		return err
+	}
+	f.Flush()
+
+	doc, err := r.render(ctx, data)
+	if err != nil {
+		io.WriteString(w, r.streamErrorHTML(ctx, data, err)+after)
+		return &StreamError{Err: err}
+	}
+
+	// the head was already sent, so elements from <svelte:head> go at the start of the body
+	_, err = io.WriteString(w, stripHead(doc.Head, data.Head)+doc.Body+after)
+	return err
+}
+
+// streamErrorHTML renders the error page of data with status 500, to be written in place of the body after rendering failed.
+// The message is only passed to the error page in development builds.
+// If the error page fails to render as well, a static error message is returned.
+func (r *Renderer) streamErrorHTML(ctx context.Context, data RenderData, err error) string {
+	message := http.StatusText(http.StatusInternalServerError)
+	if r.infofile.Dev {
+		message = err.Error()
+	}
+
+	errData := RenderData{
+		Entries:  []Entry{{Comp: data.ErrPage, Props: map[string]any{"message": message, "status": http.StatusInternalServerError}}},
+		ErrPage:  DefaultErrPage,
+		SCData:   data.SCData,
+		Nonce:    data.Nonce,
+		LogAttrs: data.LogAttrs,
+	}
+	errData.SCData.Page.Status = http.StatusInternalServerError
+
+	doc, err := r.render(ctx, errData)
+	if err != nil || doc.HasError {
+		return staticErrorHTML
+	}
+	return doc.Head + doc.Body
+}
+
+const staticErrorHTML = `<h1>500</h1><p>Internal Server Error</p>`
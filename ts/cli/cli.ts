@@ -13,8 +13,8 @@ import merge from "deepmerge";
 import replace from '@rollup/plugin-replace';
 import { Config } from "../public/config/index.js";
 import { embed } from "./templates.js";
-import { jsdir, toPosix, clean, traverseCSS } from "./util.js";
-import { ClientBuild, ComponentFile, ExtractedConfig, ViteManifest } from "./types.js";
+import { jsdir, toPosix, clean, compress, integrity, traverseCSS, traverseJS } from "./util.js";
+import { ClientBuild, ComponentFile, ExtractedConfig, ViteManifest, ViteManifestEntry } from "./types.js";
 import { pathToFileURL } from "node:url";
 
 async function main() {
@@ -132,6 +132,7 @@ async function buildClient(config: ExtractedConfig): Promise<ClientBuild> {
                 input: [
                     `./${config.template}`,
                     `./${jsdir}/client/hydrate.js`,
+                    `./${jsdir}/client/boot.js`,
                     `./${jsdir}/shared/default-error.svelte`,
                     ...config.components.map((c) => `./${c.path}`),
                 ],
@@ -146,6 +147,13 @@ async function buildClient(config: ExtractedConfig): Promise<ClientBuild> {
                 }
             },
         },
+        experimental: {
+            // urls inside of js and css are relative, so that the client build works under any base path
+            // urls in the template are prefixed by the renderer at runtime
+            renderBuiltUrl(filename, { hostType }) {
+                if (hostType === "js" || hostType === "css") return { relative: true };
+            },
+        },
         // appType: "custom",
     };
 
@@ -159,11 +167,15 @@ async function buildClient(config: ExtractedConfig): Promise<ClientBuild> {
     const templateFile = await readFile(templatePath);
     await rm(templatePath);
     
+    // integrity is computed before the compressed variants are written, which aren't loaded by the browser directly
+    const clientIntegrity = await integrity(join(config.outDir, "client"));
+    await compress(join(config.outDir, "client"));
     await clean(join(config.outDir, "client"));
 
     return {
         manifest: JSON.parse(manifestFile),
         template: templateFile,
+        integrity: clientIntegrity,
     };
 }
 
@@ -190,6 +202,7 @@ async function createManifest(components: ComponentFile[], manifest: ViteManifes
             str += `"${toPosix(join("/", base, css))}",\n`;
         }
         str += `],\n`;
+        str += `Modules: ${createModules(manifest, component, base)},\n`;
         str += `},\n`;
     }
     str += `};\n`;
@@ -197,6 +210,20 @@ async function createManifest(components: ComponentFile[], manifest: ViteManifes
     return str;
 }
 
+/** Returns the js files that a manifest entry loads, as a javascript array of urls. */
+function createModules(manifest: ViteManifest, entry: ViteManifestEntry, base: string) {
+    return JSON.stringify([...traverseJS(manifest, entry)].map((file) => toPosix(join("/", base, file))));
+}
+
+/** Returns the integrity of the client files, as a javascript object keyed by url. */
+function createIntegrity(integrity: Record<string, string>, base: string) {
+    const urls: Record<string, string> = {};
+    for (const [file, digest] of Object.entries(integrity)) {
+        urls[toPosix(join("/", base, file))] = digest;
+    }
+    return JSON.stringify(urls);
+}
+
 async function buildServer(config: ExtractedConfig, client: ClientBuild) {
     const viteConfig: UserConfig = {
         plugins: [
@@ -205,8 +232,13 @@ async function buildServer(config: ExtractedConfig, client: ClientBuild) {
             replace({
                 golteImports: await createImports(config.components),
                 golteHydrate: `"` + toPosix(join("/", config.assets, client.manifest[jsdir + "/client/hydrate.js"].file)) + `"`,
+                golteBoot: `"` + toPosix(join("/", config.assets, client.manifest[jsdir + "/client/boot.js"].file)) + `"`,
+                golteHydrateModules: createModules(client.manifest, client.manifest[jsdir + "/client/hydrate.js"], config.assets),
+                golteBootModules: createModules(client.manifest, client.manifest[jsdir + "/client/boot.js"], config.assets),
+                golteIntegrity: createIntegrity(client.integrity, config.assets),
                 golteManifest: await createManifest(config.components, client.manifest, config.assets),
                 golteAssets: `"${config.assets}"`,
+                golteDev: `${config.dev}`,
             })
         ],
         mode: config.dev ? "development" : "production",
@@ -240,6 +272,13 @@ async function buildServer(config: ExtractedConfig, client: ClientBuild) {
                 }
             },
         },
+        experimental: {
+            // urls of assets imported by components are prefixed at runtime with the asset origin and base path,
+            // which the renderer sets before loading the server build
+            renderBuiltUrl(filename) {
+                return { runtime: `golteAssetPrefix + ${JSON.stringify(toPosix(join("/", config.assets, filename)))}` };
+            },
+        },
         // appType: "custom",
     };
 
@@ -11,6 +11,9 @@ import (
 //go:embed */**
 var fsys embed.FS
 
+// FS is the build output. Pass it to golte.New to construct a middleware with custom options.
+var FS = fsys
+
 // Golte is the main middleware to register to your router. It generated by the build step.
 var Golte = golte.New(fsys)
 	`;
@@ -9,8 +9,8 @@ export type ViteManifest = Record<string, ViteManifestEntry>;
 
 export type ViteManifestEntry = {
     file: string,
-    css: string,
-    imports: string,
+    css?: string[],
+    imports?: string[],
 };
 
 export type ExtractedConfig = {
@@ -27,4 +27,6 @@ export type ExtractedConfig = {
 export type ClientBuild = {
     manifest: ViteManifest;
     template: Buffer;
+    // the subresource integrity of every file, keyed by the path relative to the client directory
+    integrity: Record<string, string>;
 };
@@ -1,5 +1,8 @@
 import { relative, dirname, join, sep, posix } from "node:path";
-import { lstat, readdir, rm } from "node:fs/promises";
+import { lstat, readdir, readFile, rm, writeFile } from "node:fs/promises";
+import { promisify } from "node:util";
+import { createHash } from "node:crypto";
+import { brotliCompress, constants, gzip } from "node:zlib";
 import { cwd } from "node:process";
 import { fileURLToPath } from "node:url";
 import { ViteManifest, ViteManifestEntry } from "./types.js";
@@ -24,6 +27,38 @@ export async function clean(path: string) {
     }
 }
 
+const compressible = /\.(js|mjs|css|html|json|map|svg|txt|xml|wasm)$/;
+const gzipAsync = promisify(gzip);
+const brotliAsync = promisify(brotliCompress);
+
+/**
+ * Recursively writes gzip and brotli compressed siblings (".gz" and ".br") of the text files in a given directory,
+ * which are served by the go file server depending on Accept-Encoding.
+ * Variants that aren't smaller than the original are not written.
+ */
+export async function compress(path: string) {
+    for (let item of await readdir(path)) {
+        item = join(path, item);
+        const stat = await lstat(item);
+        if (stat.isDirectory()) {
+            await compress(item);
+            continue;
+        }
+
+        if (!compressible.test(item) || stat.size < 1024) continue;
+
+        const content = await readFile(item);
+        const variants = {
+            ".gz": await gzipAsync(content, { level: 9 }),
+            ".br": await brotliAsync(content, { params: { [constants.BROTLI_PARAM_QUALITY]: constants.BROTLI_MAX_QUALITY } }),
+        };
+
+        for (const [ext, compressed] of Object.entries(variants)) {
+            if (compressed.length < content.length) await writeFile(item + ext, compressed);
+        }
+    }
+}
+
 /** Get the css from a manifest entry and its dependencies. */
 export function traverseCSS(manifest: ViteManifest, component: ViteManifestEntry) {
     const css = new Set(component.css);
@@ -37,4 +72,35 @@ export function traverseCSS(manifest: ViteManifest, component: ViteManifestEntry
     }
 
     return css;
-}
\ No newline at end of file
+}
+
+/** Get the js files that are statically imported by a manifest entry, including its own file. */
+export function traverseJS(manifest: ViteManifest, component: ViteManifestEntry) {
+    const js = new Set([component.file]);
+
+    for (const i of component.imports ?? []) {
+        if (!(i in manifest)) continue;
+        for (const j of traverseJS(manifest, manifest[i])) {
+            js.add(j);
+        }
+    }
+
+    return js;
+}
+
+/** Recursively computes the sha384 subresource integrity of every file in a given directory, keyed by the posix path relative to it. */
+export async function integrity(path: string, root = path) {
+    const result: Record<string, string> = {};
+    for (let item of await readdir(path)) {
+        item = join(path, item);
+        const stat = await lstat(item);
+        if (stat.isDirectory()) {
+            Object.assign(result, await integrity(item, root));
+            continue;
+        }
+
+        const digest = createHash("sha384").update(await readFile(item)).digest("base64");
+        result[toPosix(relative(root, item))] = `sha384-${digest}`;
+    }
+    return result;
+}
@@ -0,0 +1,10 @@
+// This module hydrates the app without an inline script, for use with a strict content security policy.
+// The data is read from the json script written by the renderer, whose parent is the target.
+
+import { hydrate } from "./hydrate.js";
+
+const script = document.querySelector<HTMLScriptElement>("script[data-golte-hydrate]");
+if (script?.parentElement) {
+    const { nodes, contextData } = JSON.parse(script.textContent ?? "");
+    hydrate(script.parentElement, nodes, contextData, script.nonce);
+}
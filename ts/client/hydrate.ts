@@ -1,7 +1,10 @@
 import Root from "../shared/Root.svelte";
+import { restoreTitle, setNonce } from "../shared/appstate.js";
 import type { ClientNode, ContextData } from "../shared/types.js"
 
-export async function hydrate(target: HTMLElement, nodes: ClientNode[], contextData: ContextData) {
+export async function hydrate(target: HTMLElement, nodes: ClientNode[], contextData: ContextData, nonce = "") {
+    setNonce(nonce);
+
     const promise = Promise.all(nodes.map(async (n) => ({
         comp: (await import(n.comp)).default,
         props: n.props,
@@ -17,4 +20,7 @@ export async function hydrate(target: HTMLElement, nodes: ClientNode[], contextD
         },
         hydrate: true,
     });
+
+    // components with a title in <svelte:head> set it while hydrating
+    restoreTitle();
 }
\ No newline at end of file
@@ -8,8 +8,11 @@ type Preload = "mount" | "tap" | "hover";
  * Will cause the link to preload and render on the client.
  */
 export const preload = ((a: HTMLAnchorElement, preload: Preload = "hover") => {
+    // links outside of the app are navigated to normally
+    const internal = () => a.origin === location.origin && (a.pathname + "/").startsWith(state.base + "/");
+
     async function loadAnchor() {
-        if (a.origin !== location.origin) return;
+        if (!internal()) return;
         if (a.href in state.hrefMap) return;
         state.hrefMap[a.href] = load(a.href);
     }
@@ -22,7 +25,7 @@ export const preload = ((a: HTMLAnchorElement, preload: Preload = "hover") => {
     }
 
     a.addEventListener("click", async (e) => {
-        if (a.origin !== location.origin) return;
+        if (!internal()) return;
         e.preventDefault();
         await goto(a.href);
     });
@@ -1,5 +1,5 @@
 import type { Readable, Subscriber } from "svelte/store";
-import { state } from "../../shared/appstate.js";
+import { state, type Page } from "../../shared/appstate.js";
 
 // A svelte store representing the current url.
 export const url: Readable<URL> = {
@@ -7,3 +7,11 @@ export const url: Readable<URL> = {
 		return state.url.subscribe(fn);
 	}
 };
+
+// A svelte store describing the current page: the status code, the route pattern and parameters,
+// and the request headers and cookies that were allowed to be exposed.
+export const page: Readable<Page> = {
+	subscribe(fn: Subscriber<Page>) {
+		return state.page.subscribe(fn);
+	}
+};
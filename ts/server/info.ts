@@ -1,2 +1,5 @@
 // @ts-ignore
 export let Assets = golteAssets;
+
+// @ts-ignore
+export let Dev = golteDev;
@@ -13,78 +13,149 @@ golteImports;
 // @ts-ignore
 const hydrate = golteHydrate;
 
+// @ts-ignore
+const boot = golteBoot;
+
 // @ts-ignore
 export const Manifest = golteManifest;
 
+// the subresource integrity of every client file, keyed by url
+// @ts-ignore
+export const Integrity: Record<string, string> = golteIntegrity;
+
+// the js files that are loaded by the hydration modules, so they can be preloaded with their integrity
+// @ts-ignore
+export const HydrateModules: string[] = golteHydrateModules;
+// @ts-ignore
+export const BootModules: string[] = golteBootModules;
+
 type Entry = {
     Comp: string;
     Props: Record<string, any>;
+    // the error component for the components below this one, if any
+    ErrPage?: string;
 };
 
 type ServerNode = {
     comp: any,
     props: Record<string, any>,
     errPage: any,
+    name: string,
+    errName: string,
 };
 
 
 type SSRError = {
     index: number,
     props: ErrorProps,
+    error: unknown,
 };
 
-export function Render(entries: Entry[], contextData: ContextData, errPage: string) {
+// how the hydration script is written
+type HydrateOptions = {
+    // the nonce for the content security policy, if any
+    Nonce: string,
+    // if true, the data is written as json and read by an external module, so there is no inline script to execute
+    External: boolean,
+    // the asset origin and base path, which asset paths of the manifest are prefixed with
+    AssetPrefix: string,
+    // if true, the html is not hydrated on the client, so no script is written
+    NoHydrate: boolean,
+};
+
+// the description of an error that is passed to go
+type ErrorInfo = {
+    Component: string,
+    Message: string,
+    Stack: string,
+};
+
+export function Render(entries: Entry[], contextData: ContextData, errPage: string, opts: HydrateOptions) {
     const serverNodes: ServerNode[] = [];
     const clientNodes: ClientNode[] = [];
-    const stylesheets = new Set<string>();
 
-    const err = Manifest[errPage];
-    if (!err) throw new Error(`"${errPage}" is not a component`);
+    // asset paths in the manifest are relative to the root, so they are prefixed with the asset origin and base path
+    const base = opts.AssetPrefix;
+
+    // each component uses the error component of the nearest entry above it that has one
+    let errName = errPage;
+    let err = Manifest[errName];
+    if (!err) throw new Error(`"${errName}" is not a component`);
 
     for (const e of entries) {
         const c = Manifest[e.Comp];
         if (!c) throw new Error(`"${e.Comp}" is not a component`);
-        serverNodes.push({ comp: c.server, props: e.Props, errPage: err.server });
-        clientNodes.push({ comp: `${c.Client}`, props: e.Props, errPage: `${err.Client}` });
-        for (const path of c.CSS) {
-            stylesheets.add(path);
-        }
-    }
+        serverNodes.push({ comp: c.server, props: e.Props, errPage: err.server, name: e.Comp, errName });
+        clientNodes.push({ comp: `${base}${c.Client}`, props: e.Props, errPage: `${base}${err.Client}` });
 
-    for (const path of err.CSS) {
-        stylesheets.add(path);
+        if (e.ErrPage) {
+            errName = e.ErrPage;
+            err = Manifest[errName];
+            if (!err) throw new Error(`"${errName}" is not a component`);
+        }
     }
 
     let error: SSRError | undefined;
     const context = new Map(); // TODO dont use context for this
     context.set(handleError, (e: any) => error = e ) 
+    // stylesheet links are added by the renderer in go
     let { html, head } = Root.render({ nodes: serverNodes, contextData }, { context });
 
-    for (const path of stylesheets) {
-        head += `\n<link href="${path}" rel="stylesheet">`;
-    }
-
     if (error) {
         clientNodes[error.index].ssrError = error.props;
+        // the response is sent with status 500 when the error page was rendered, so the page store has to match
+        contextData = { ...contextData, Page: { ...contextData.Page, Status: 500 } };
     }
 
-    html += `
-        <script>
-            (async function () {
-                const target = document.currentScript.parentElement;
-                const { hydrate } = await import("${hydrate}");
-                await hydrate(target, ${stringify(clientNodes)}, ${stringify(contextData)});
-            })();
-        </script>
-    `;
+    // static html, such as the body of an email, is not hydrated
+    if (!opts.NoHydrate) {
+        const nonce = opts.Nonce ? ` nonce="${escapeAttr(opts.Nonce)}"` : "";
+        if (opts.External) {
+            html += `
+            <script type="application/json" data-golte-hydrate${nonce}>${stringify({ nodes: clientNodes, contextData })}</script>
+            <script type="module" src="${base}${boot}"${nonce}${integrity(boot)}></script>
+        `;
+        } else {
+            html += `
+            <script${nonce}>
+                (async function () {
+                    const script = document.currentScript;
+                    const { hydrate } = await import("${base}${hydrate}");
+                    await hydrate(script.parentElement, ${stringify(clientNodes)}, ${stringify(contextData)}, script.nonce);
+                })();
+            </script>
+        `;
+        }
+    }
 
     return {
         Head: head,
         Body: html,
         HasError: !!error,
+        Error: error && errorInfo(error.error),
     }
 }
 
+function errorInfo(err: any): ErrorInfo {
+    return {
+        Component: err?.component ?? "",
+        Message: err instanceof Error ? err.message : String(err),
+        Stack: err instanceof Error ? err.stack ?? "" : "",
+    };
+}
+
+function integrity(url: string) {
+    const digest = Integrity[url];
+    return digest ? ` integrity="${digest}" crossorigin="anonymous"` : "";
+}
+
+function escapeAttr(value: string) {
+    return value.replace(/&/g, "&amp;").replace(/"/g, "&quot;").replace(/</g, "&lt;");
+}
+
+// Serializes the object for an inline script or a json script element.
+// Every character that could end the script element or start a comment is escaped,
+// along with the line terminators that are not allowed in js strings by older browsers.
 function stringify(object: any) {
-    return JSON.stringify(object).replace("</script>", "<\\/script>");
+    return JSON.stringify(object).replace(/[<>&\u2028\u2029]/g, (c) => `\\u${c.charCodeAt(0).toString(16).padStart(4, "0")}`);
 }
@@ -1,42 +1,104 @@
+import { tick } from "svelte";
 import { get, Writable, writable } from "svelte/store";
 import { fromArray, StoreList } from "./list.js";
-import { CompState } from "./types.js";
+import { CompState, ContextData, PageData } from "./types.js";
 
 type CSRResponse = {
+    Status: number,
     Entries: ResponseEntry[],
-    ErrPage: ResponseEntry,
+    Head: HeadTag[] | null,
+    ContextData: ContextData,
+    Integrity: Record<string, string> | null,
+}
+
+// An element of the head that was added from go.
+type HeadTag = {
+    Tag: string,
+    Attrs: Record<string, string> | null,
+    Content: string,
 }
 
 type ResponseEntry = {
     File: string,
     Props: Record<string, any>,
-    CSS: string[],
+    CSS: string[], // includes the css of the error page
+    Modules: string[] | null, // includes the js of the error page
+    ErrPage: string,
 }
 
+export type Loaded = {
+    nodes: CompState[],
+    contextData: ContextData,
+    head: HeadTag[],
+};
+
+// The value of the page store.
+export type Page = {
+    status: number,
+    route: string,
+    params: Record<string, string>,
+    headers: Record<string, string>,
+    cookies: Record<string, string>,
+};
+
 export let state: {
+    base: string;
     url: Writable<URL>;
+    page: Writable<Page>;
     node: StoreList<CompState>;
+    context: Record<string, Writable<any>>;
 
     // client only properties below
-    hrefMap: Record<string, Promise<CompState[]>>;
+    hrefMap: Record<string, Promise<Loaded>>;
     update: (href: string) => Promise<void>;
 };
 
-export function initState(url: string, nodes: CompState[]) {
+// the nonce for the content security policy, which is needed for the elements that are added on navigation
+let nonce = "";
+
+export function setNonce(value: string) {
+    nonce = value;
+}
+
+// the title that was set from go, which takes precedence over the titles of components
+let title: string | undefined;
+
+// Sets the title from go again, after components have set document.title.
+export function restoreTitle() {
+    if (title !== undefined) document.title = title;
+}
+
+export function initState(contextData: ContextData, nodes: CompState[]) {
     state = {} as typeof state;
     
-    state.url = writable(new URL(url));
+    state.base = contextData.BasePath ?? "";
+    state.url = writable(new URL(contextData.URL));
+    state.page = writable(toPage(contextData.Page));
     state.node = fromArray(nodes);
+    state.context = {};
+    for (const [key, value] of Object.entries(contextData.Context ?? {})) {
+        state.context[key] = writable(value);
+    }
 
     if (!import.meta.env.SSR) {
+        const head = currentHead();
+        title = head.find((tag) => tag.Tag === "title")?.Content;
         state.hrefMap = {
-            [url]: new Promise(r => r(nodes)),
+            [contextData.URL]: new Promise(r => r({ nodes, contextData, head })),
         };
     
         state.update = async (href: string) => {
             state.url.set(new URL(href))
         
-            const array = await (state.hrefMap[href] ?? load(href));
+            const { nodes: array, contextData, head } = await (state.hrefMap[href] ?? load(href));
+            state.page.set(toPage(contextData.Page));
+            applyHead(head);
+
+            // contexts can only be set when the root is created, so only existing keys are updated
+            const values = contextData.Context ?? {};
+            for (const key in state.context) {
+                state.context[key].set(values[key]);
+            }
         
             // this loop replaces the first differentiated node from after onto before
             // the reason this is done instead of simply replacing the first node is so we don't rerender unnecessary nodes
@@ -65,6 +127,9 @@ export function initState(url: string, nodes: CompState[]) {
                     break;
                 }
             }
+
+            await tick();
+            restoreTitle();
         }
     }
 }
@@ -72,28 +137,88 @@ export function initState(url: string, nodes: CompState[]) {
 // export const AppState: typeof ClientAppState = import.meta.env.SSR ? ServerAppState : ClientAppState as any;
 // export type AppState = ClientAppState;
 
-export async function load(href: string) {
+function toPage(data: PageData): Page {
+    return {
+        status: data.Status,
+        route: data.Route,
+        params: { ...data.Params },
+        headers: { ...data.Headers },
+        cookies: { ...data.Cookies },
+    };
+}
+
+// the attribute of head elements that were added from go
+const headAttr = "data-golte-head";
+
+// Returns the head elements that were added from go during ssr.
+function currentHead(): HeadTag[] {
+    return Array.from(document.head.querySelectorAll(`[${headAttr}]`), (el) => {
+        const attrs: Record<string, string> = {};
+        for (const attr of Array.from(el.attributes)) {
+            if (attr.name !== headAttr) attrs[attr.name] = attr.value;
+        }
+        return { Tag: el.tagName.toLowerCase(), Attrs: attrs, Content: el.textContent ?? "" };
+    });
+}
+
+// Replaces the head elements of the previous page with the ones of the new page.
+function applyHead(tags: HeadTag[]) {
+    title = tags.find((tag) => tag.Tag === "title")?.Content;
+    document.head.querySelectorAll(`[${headAttr}]`).forEach((el) => el.remove());
+    for (const tag of tags) {
+        const el = document.createElement(tag.Tag);
+        for (const [name, value] of Object.entries(tag.Attrs ?? {})) {
+            el.setAttribute(name, value);
+        }
+        el.setAttribute(headAttr, "");
+        if (nonce) el.nonce = nonce;
+        el.textContent = tag.Content;
+        document.head.appendChild(el);
+    }
+}
+
+function createLink(href: string, rel: string, integrity?: string) {
+    const link = document.createElement("link");
+    link.href = href;
+    link.rel = rel;
+    if (integrity) {
+        link.integrity = integrity;
+        link.crossOrigin = "anonymous";
+    }
+    if (nonce) link.nonce = nonce;
+    return link;
+}
+
+export async function load(href: string): Promise<Loaded> {
     const headers = { "Golte": "true" };
     const resp = await fetch(href, { headers });
     const json: CSRResponse = await resp.json();
 
-    for (const entry of [...json.Entries, json.ErrPage]) {
+    const integrity = json.Integrity ?? {};
+    for (const entry of json.Entries) {
         // load css
         for (const css of entry.CSS) {
             if (document.querySelector(`link[href="${css}"][rel="stylesheet"]`)) continue;
-            const link = document.createElement("link");
-            link.href = css;
-            link.rel = "stylesheet";
-            document.head.appendChild(link);
+            document.head.appendChild(createLink(css, "stylesheet", integrity[css]));
         }
         // TODO send css as its own field, outside of the array
+
+        // modules with an integrity are preloaded before they are imported, so the import is verified
+        for (const js of entry.Modules ?? []) {
+            if (!integrity[js] || document.querySelector(`link[href="${js}"][rel="modulepreload"]`)) continue;
+            document.head.appendChild(createLink(js, "modulepreload", integrity[js]));
+        }
     }
 
     const promises = json.Entries.map(async (entry) => ({
         comp: (await import(entry.File)).default,
         props: entry.Props,
-        errPage: (await import(json.ErrPage.File)).default,
+        errPage: (await import(entry.ErrPage)).default,
     }));
 
-    return await Promise.all(promises);
+    return {
+        nodes: await Promise.all(promises),
+        contextData: json.ContextData,
+        head: json.Head ?? [],
+    };
 }
@@ -11,9 +11,12 @@ const ServerNode: ServerComponent = ClientNode as any;
 const ssrWrapper: ServerComponent = {
     ...ServerNode,
     $$render: (result, props, bindings, slots, context) => {
+        const { name, errPage, errName } = props.node.content;
         try {
             return ServerNode.$$render(result, props, bindings, slots, context);
         } catch (err) {
+            withComponent(err, name);
+
             let message = "Internal Error";
             if (import.meta.env.MODE === "development") {
                 message = (err instanceof Error && err.stack) ? err.stack : String(err);
@@ -24,12 +27,25 @@ const ssrWrapper: ServerComponent = {
                 message,
             };
             
-            getContext<Function>(handleError)({ index: props.index, props: errProps });
-            return props.node.content.errPage.$$render(result, errProps, bindings, slots, context);
+            getContext<Function>(handleError)({ index: props.index, props: errProps, error: err });
+            try {
+                return errPage.$$render(result, errProps, bindings, slots, context);
+            } catch (err) {
+                throw withComponent(err, errName);
+            }
         }
     }
 };
 
+// Records the name of the component that threw the error, so it can be reported by the renderer.
+// The innermost component is kept if the error is rethrown by its ancestors.
+function withComponent(err: unknown, component: string) {
+    if (err instanceof Object && !("component" in err)) {
+        (err as any).component = component;
+    }
+    return err;
+}
+
 function csrWrapper(options: any): ClientNode {
     // if there as an error during ssr, don't render anything new
     const ssrError = options.props.node.content.ssrError;
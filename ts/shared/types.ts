@@ -12,7 +12,20 @@ export type ClientNode = {
     ssrError?: ErrorProps,
 }
 
-export type ContextData = Record<string, any>;
+export type ContextData = {
+    URL: string,
+    Page: PageData,
+    BasePath: string,
+    Context: Record<string, any> | null,
+};
+
+export type PageData = {
+    Status: number,
+    Route: string,
+    Params: Record<string, string> | null,
+    Headers: Record<string, string> | null,
+    Cookies: Record<string, string> | null,
+};
 
 export type ServerComponent = {
     render(props: Record<string, any>, opts: { context: Map<any, any> }): {